@@ -5,54 +5,150 @@ import (
 	"go/ast"
 	"go/parser"
 	"go/token"
+	"go/types"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
+
+	"golang.org/x/tools/go/packages"
 )
 
 // parseResult holds the intermediate result of parsing project files
 type parseResult struct {
 	fileFunctions map[string][]FuncInfo
 	fileTests     map[string][]TestInfo
+	helpers       []testHelper
+	typed         bool                // true if calls were resolved using type information
+	pkgs          []*packages.Package // type-checked packages, nil for syntax-only parsing
+
+	ifaceMethods  map[string]*types.Func  // interface methods called from tests (type-checked only)
+	concreteTypes map[string]*types.Named // concrete module types (type-checked only)
+}
+
+// testHelper is a non-test function or method declared in a _test.go file
+type testHelper struct {
+	FuncInfo
+	calls      TestInfo // calls made by the helper, in the same form as for tests
+	resultType string   // type name of the first result, for constructors such as newFixture
+	aliasOf    string   // call name of the function aliased by a package-level variable (syntax only)
+}
+
+// newParseResult creates an empty parseResult
+func newParseResult() *parseResult {
+	return &parseResult{
+		fileFunctions: make(map[string][]FuncInfo),
+		fileTests:     make(map[string][]TestInfo),
+	}
+}
+
+// analysisOptions configures the project analysis
+type analysisOptions struct {
+	excludePrivate bool
+	verbose        bool
+	callGraph      string // call graph algorithm ("cha" or "rta"), empty to disable
+	maxDepth       int    // maximum call depth followed from a test (0 for unlimited)
+
+	requireBlackBox bool   // report exported functions not called from external test packages
+	testMatrix      string // run tests in isolation per "test" or "package" to attribute coverage, empty to disable
+
+	goTest goTestConfig // options of the go test runs of the test matrix
 }
 
-func analyzeProject(dir string, excludePrivate, verbose bool, coverageMap map[string]float64) (*AnalysisResult, error) {
-	parsed, err := parseProjectFiles(dir, excludePrivate, verbose)
+func analyzeProject(dir string, opts analysisOptions, coverage *coverageData) (*AnalysisResult, error) {
+	parsed, err := parseProjectFiles(dir, opts.excludePrivate, opts.verbose)
 	if err != nil {
 		return nil, err
 	}
 
 	testedFuncs := buildTestedFuncsMap(parsed.fileTests)
-	functionsWithoutTests := findFunctionsWithoutTests(parsed.fileFunctions, testedFuncs, coverageMap)
-	misplacedTests := findMisplacedTests(parsed.fileTests, parsed.fileFunctions)
+	evidence := testEvidence{
+		calledFuncs:     testedFuncs,
+		referencedFuncs: buildReferencedFuncsMap(parsed.fileTests),
+	}
+	if coverage != nil {
+		evidence.coverageMap = coverage.byPosition()
+		evidence.integrationOnly = coverage.integrationOnly()
+		evidence.unknownDirs = coverage.unknownDirs()
+	}
+	evidence.blackBoxCalls, evidence.whiteBoxCalls = buildTestedFuncsMapsByStyle(parsed.fileTests)
 
-	return &AnalysisResult{
-		FunctionsWithoutTests: functionsWithoutTests,
-		MisplacedTests:        misplacedTests,
-	}, nil
-}
+	var unexercisedImpls []UnexercisedImpl
+	if parsed.typed {
+		evidence.exercisedImpls, unexercisedImpls = analyzeInterfaceDispatch(parsed, testedFuncs)
+	}
 
-// parseProjectFiles walks the directory and parses all Go files
-func parseProjectFiles(dir string, excludePrivate, verbose bool) (*parseResult, error) {
-	fileFunctions := make(map[string][]FuncInfo)
-	fileTests := make(map[string][]TestInfo)
-	fset := token.NewFileSet()
+	if opts.callGraph != "" {
+		if !parsed.typed {
+			fmt.Fprintf(os.Stderr, "Warning: call graph analysis requires a Go module, skipping\n")
+		} else {
+			evidence.chains, err = buildReachabilityChains(parsed.pkgs, opts.callGraph, opts.maxDepth)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	result := &AnalysisResult{
+		Functions:        classifyFunctions(parsed.fileFunctions, evidence),
+		Tests:            sortedTests(parsed.fileTests),
+		UnexercisedImpls: unexercisedImpls,
+		MisplacedTests:   findMisplacedTests(parsed.fileTests, parsed.fileFunctions),
+	}
 
-	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+	if opts.testMatrix != "" {
+		matrix, err := buildCoverageMatrix(dir, parsed.fileTests, opts.testMatrix, opts.goTest, opts.verbose)
 		if err != nil {
-			return err
+			return nil, err
 		}
+		result.TestMatrix, result.IncidentallyCoveredFuncs = matrix.funcReport(result.Functions)
+		result.RedundantTests = matrix.redundantTests()
+	}
 
-		if shouldSkipDir(info) {
-			return filepath.SkipDir
+	for _, f := range result.Functions {
+		if opts.requireBlackBox && f.TestStyle == TestStyleWhiteBox && isExportedFunc(f) {
+			result.WhiteBoxOnlyFuncs = append(result.WhiteBoxOnlyFuncs, f)
 		}
-
-		if info.IsDir() || !strings.HasSuffix(path, ".go") {
-			return nil
+		switch f.Verdict {
+		case VerdictUntested:
+			result.FunctionsWithoutTests = append(result.FunctionsWithoutTests, f)
+		case VerdictReferenced:
+			result.ReferencedOnlyFuncs = append(result.ReferencedOnlyFuncs, f)
+		case VerdictIntegrationOnly:
+			result.IntegrationOnlyFuncs = append(result.IntegrationOnlyFuncs, f)
+		case VerdictCoverageUnknown:
+			result.CoverageUnknownFuncs = append(result.CoverageUnknownFuncs, f)
+		case VerdictCallGraph:
+			result.IndirectlyTestedFuncs = append(result.IndirectlyTestedFuncs, ReachedFunc{Func: f, Chain: evidence.chains[f.Key()]})
+		case VerdictReceiverMatch, VerdictSuffixHeuristic, VerdictInterfaceDispatch, VerdictCoverageOnly:
+			result.HeuristicallyTestedFuncs = append(result.HeuristicallyTestedFuncs, f)
 		}
+	}
 
-		relPath, _ := filepath.Rel(dir, path)
+	return result, nil
+}
+
+// parseProjectFiles parses all Go files in the directory. It loads the packages
+// with full type information when possible and falls back to syntax-only
+// parsing otherwise (e.g. when the directory is not part of a Go module).
+func parseProjectFiles(dir string, excludePrivate, verbose bool) (*parseResult, error) {
+	pkgs, err := loadTypedPackages(dir)
+	if err == nil {
+		return parseTypedPackages(dir, pkgs, excludePrivate, verbose)
+	}
+	if verbose {
+		fmt.Fprintf(os.Stderr, "Warning: type-checked analysis unavailable, using syntax only: %v\n", err)
+	}
+	return parseSyntaxOnly(dir, excludePrivate, verbose)
+}
+
+// parseSyntaxOnly walks the directory and parses all Go files without type information
+func parseSyntaxOnly(dir string, excludePrivate, verbose bool) (*parseResult, error) {
+	result := newParseResult()
+	fset := token.NewFileSet()
+
+	err := walkGoFiles(dir, func(path, relPath string) error {
 		file, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
 		if err != nil {
 			if verbose {
@@ -62,7 +158,7 @@ func parseProjectFiles(dir string, excludePrivate, verbose bool) (*parseResult,
 		}
 
 		isTestFile := strings.HasSuffix(path, "_test.go")
-		processFileDeclarations(file, fset, relPath, isTestFile, excludePrivate, fileFunctions, fileTests)
+		processFileDeclarations(file, fset, relPath, isTestFile, excludePrivate, result, nil)
 
 		return nil
 	})
@@ -71,10 +167,30 @@ func parseProjectFiles(dir string, excludePrivate, verbose bool) (*parseResult,
 		return nil, err
 	}
 
-	return &parseResult{
-		fileFunctions: fileFunctions,
-		fileTests:     fileTests,
-	}, nil
+	result.helpers = dropForeignAliases(result.helpers, result.fileFunctions)
+	propagateHelperCalls(result.fileTests, result.helpers)
+	return result, nil
+}
+
+// walkGoFiles calls fn with the absolute and dir-relative path of every Go file
+// under dir, skipping the directories the go tool ignores
+func walkGoFiles(dir string, fn func(path, relPath string) error) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if shouldSkipDir(info) {
+			return filepath.SkipDir
+		}
+
+		if info.IsDir() || !strings.HasSuffix(path, ".go") {
+			return nil
+		}
+
+		relPath, _ := filepath.Rel(dir, path)
+		return fn(path, relPath)
+	})
 }
 
 // shouldSkipDir returns true if the directory should be skipped
@@ -86,9 +202,29 @@ func shouldSkipDir(info os.FileInfo) bool {
 	return name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".")
 }
 
-// processFileDeclarations extracts function and test declarations from a parsed file
-func processFileDeclarations(file *ast.File, fset *token.FileSet, relPath string, isTestFile, excludePrivate bool, fileFunctions map[string][]FuncInfo, fileTests map[string][]TestInfo) {
+// processFileDeclarations extracts function, test and test helper declarations from a parsed file.
+// If tc is non-nil, calls are resolved using the package's type information.
+func processFileDeclarations(file *ast.File, fset *token.FileSet, relPath string, isTestFile, excludePrivate bool, result *parseResult, tc *typeContext) {
+	pkgName := file.Name.Name
+	var importedPkgs []string
+	var underTest string
+	external := isTestFile && strings.HasSuffix(pkgName, "_test")
+	if isTestFile {
+		importedPkgs = importedPackageNames(file)
+	}
+	if external {
+		underTest = packageUnderTestImport(file, strings.TrimSuffix(pkgName, "_test"))
+	}
+
 	for _, decl := range file.Decls {
+		if genDecl, ok := decl.(*ast.GenDecl); ok {
+			if isTestFile {
+				processExportAliases(genDecl, fset, relPath, pkgName, result, tc)
+			} else {
+				processFuncLitUnits(genDecl, fset, relPath, pkgName, excludePrivate, result, tc)
+			}
+			continue
+		}
 		funcDecl, ok := decl.(*ast.FuncDecl)
 		if !ok {
 			continue
@@ -98,14 +234,37 @@ func processFileDeclarations(file *ast.File, fset *token.FileSet, relPath string
 		pos := fset.Position(funcDecl.Pos())
 
 		if isTestFile {
+			testInfo := TestInfo{
+				Name:             funcName,
+				File:             relPath,
+				Line:             pos.Line,
+				PackageName:      pkgName,
+				ImportedPackages: importedPkgs,
+				External:         external,
+			}
+			if tc != nil {
+				tc.resolveCalls(funcDecl, &testInfo)
+			} else {
+				testInfo.CalledFuncs = trimPackageQualifier(extractCalledFunctions(funcDecl), underTest)
+				testInfo.ReferencedFuncs = trimPackageQualifier(extractReferencedFunctions(funcDecl), underTest)
+				testInfo.MethodCalls = extractMethodCalls(funcDecl)
+			}
+
 			if isTestFunction(funcName) {
-				testInfo := TestInfo{
-					Name:        funcName,
-					File:        relPath,
-					Line:        pos.Line,
-					CalledFuncs: extractCalledFunctions(funcDecl),
+				result.fileTests[relPath] = append(result.fileTests[relPath], testInfo)
+			} else {
+				helper := testHelper{
+					FuncInfo: buildFuncInfo(funcDecl, funcName, relPath, pos.Line),
+					calls:    testInfo,
 				}
-				fileTests[relPath] = append(fileTests[relPath], testInfo)
+				helper.PackageName = pkgName
+				if results := funcDecl.Type.Results; results != nil && len(results.List) > 0 {
+					helper.resultType = getReceiverType(results.List[0].Type)
+				}
+				if tc != nil {
+					helper.Package = tc.pkgPath
+				}
+				result.helpers = append(result.helpers, helper)
 			}
 		} else {
 			if funcName == "init" || funcName == "main" {
@@ -116,7 +275,298 @@ func processFileDeclarations(file *ast.File, fset *token.FileSet, relPath string
 			}
 
 			funcInfo := buildFuncInfo(funcDecl, funcName, relPath, pos.Line)
-			fileFunctions[relPath] = append(fileFunctions[relPath], funcInfo)
+			funcInfo.PackageName = pkgName
+			if tc != nil {
+				funcInfo.Package = tc.pkgPath
+			}
+			result.fileFunctions[relPath] = append(result.fileFunctions[relPath], funcInfo)
+		}
+	}
+}
+
+// processExportAliases records package-level variables of test files that
+// alias functions of the package under test (var ParseHeader = parseHeader, as
+// in export_test.go files exposing internals to external test packages) as test
+// helpers calling the aliased function. Without type information, aliases of
+// names that are not functions of the package are dropped by
+// dropForeignAliases once all files are parsed.
+func processExportAliases(genDecl *ast.GenDecl, fset *token.FileSet, relPath, pkgName string, result *parseResult, tc *typeContext) {
+	if genDecl.Tok != token.VAR {
+		return
+	}
+	for _, spec := range genDecl.Specs {
+		valueSpec, ok := spec.(*ast.ValueSpec)
+		if !ok {
+			continue
+		}
+		for i, name := range valueSpec.Names {
+			if i >= len(valueSpec.Values) || name.Name == "_" {
+				continue
+			}
+			helper := testHelper{
+				FuncInfo: FuncInfo{
+					Name:        name.Name,
+					File:        relPath,
+					Line:        fset.Position(name.Pos()).Line,
+					PackageName: pkgName,
+				},
+			}
+			if tc != nil {
+				helper.Package = tc.pkgPath
+				tc.resolveAlias(valueSpec.Values[i], &helper.calls)
+			} else if aliased := aliasedFuncName(valueSpec.Values[i]); aliased != "" {
+				helper.aliasOf = aliased
+				helper.calls.CalledFuncs = []string{aliased}
+			}
+			if len(helper.calls.CalledFuncs) > 0 {
+				result.helpers = append(result.helpers, helper)
+			}
+		}
+	}
+}
+
+// aliasedFuncName returns the call name of the function a package-level
+// variable may alias: a function (parseHeader, or Map[int] with type
+// arguments), a method expression ((*Server).Handle as Server_Handle) or a
+// function literal unit (DefaultServer.Handler as DefaultServer_Handler)
+func aliasedFuncName(value ast.Expr) string {
+	switch v := unwrapTypeArgs(ast.Unparen(value)).(type) {
+	case *ast.Ident:
+		return v.Name
+	case *ast.SelectorExpr:
+		x := ast.Unparen(v.X)
+		if star, ok := x.(*ast.StarExpr); ok {
+			x = ast.Unparen(star.X)
+		}
+		if ident, ok := x.(*ast.Ident); ok {
+			return ident.Name + "_" + v.Sel.Name
+		}
+	}
+	return ""
+}
+
+// dropForeignAliases removes the aliases (see processExportAliases) whose
+// aliased name is not a function of the alias's own package
+func dropForeignAliases(helpers []testHelper, fileFunctions map[string][]FuncInfo) []testHelper {
+	declared := make(map[string]bool)
+	for _, funcs := range fileFunctions {
+		for _, f := range funcs {
+			callName := strings.Replace(f.Name, ".", "_", 1)
+			if f.Receiver != "" {
+				callName = f.Receiver + "_" + f.Name
+			}
+			declared[scopedName(packageScope(f.File, f.PackageName), callName)] = true
+		}
+	}
+
+	var kept []testHelper
+	for _, h := range helpers {
+		if h.aliasOf == "" || declared[scopedName(packageScope(h.File, h.PackageName), h.aliasOf)] {
+			kept = append(kept, h)
+		}
+	}
+	return kept
+}
+
+// funcLitUnit is a function literal declared at package level, either as the
+// value of a variable (var parseHeader = func(...) {...}) or as a field value
+// in a variable's composite literal (var DefaultServer = Server{Handler: func(...) {...}})
+type funcLitUnit struct {
+	name string // variable name, or Var.Field for field values
+	lit  *ast.FuncLit
+}
+
+// extractFuncLitUnits returns the function literals declared by a package-level var declaration
+func extractFuncLitUnits(genDecl *ast.GenDecl) []funcLitUnit {
+	if genDecl.Tok != token.VAR {
+		return nil
+	}
+
+	var units []funcLitUnit
+	for _, spec := range genDecl.Specs {
+		valueSpec, ok := spec.(*ast.ValueSpec)
+		if !ok {
+			continue
+		}
+		for i, name := range valueSpec.Names {
+			if i >= len(valueSpec.Values) || name.Name == "_" {
+				continue
+			}
+			value := ast.Unparen(valueSpec.Values[i])
+			if unary, ok := value.(*ast.UnaryExpr); ok && unary.Op == token.AND {
+				value = unary.X
+			}
+			switch v := value.(type) {
+			case *ast.FuncLit:
+				units = append(units, funcLitUnit{name: name.Name, lit: v})
+			case *ast.CompositeLit:
+				for _, elt := range v.Elts {
+					kv, ok := elt.(*ast.KeyValueExpr)
+					if !ok {
+						continue
+					}
+					key, keyOK := kv.Key.(*ast.Ident)
+					lit, litOK := ast.Unparen(kv.Value).(*ast.FuncLit)
+					if keyOK && litOK {
+						units = append(units, funcLitUnit{name: name.Name + "." + key.Name, lit: lit})
+					}
+				}
+			}
+		}
+	}
+	return units
+}
+
+// processFuncLitUnits adds the function literals declared by a package-level
+// var declaration to the functions of the file
+func processFuncLitUnits(genDecl *ast.GenDecl, fset *token.FileSet, relPath, pkgName string, excludePrivate bool, result *parseResult, tc *typeContext) {
+	for _, unit := range extractFuncLitUnits(genDecl) {
+		if excludePrivate && !isExportedUnit(unit.name) {
+			continue
+		}
+		funcInfo := FuncInfo{
+			Name:        unit.name,
+			File:        relPath,
+			Line:        fset.Position(unit.lit.Pos()).Line,
+			PackageName: pkgName,
+		}
+		if tc != nil {
+			funcInfo.Package = tc.pkgPath
+		}
+		result.fileFunctions[relPath] = append(result.fileFunctions[relPath], funcInfo)
+	}
+}
+
+// isExportedUnit reports whether a function literal unit name (Var or Var.Field) is exported
+func isExportedUnit(name string) bool {
+	for part := range strings.SplitSeq(name, ".") {
+		if !ast.IsExported(part) {
+			return false
+		}
+	}
+	return true
+}
+
+// importedPackageNames returns the names under which a file imports packages:
+// the explicit import name, or the last import path element otherwise
+// (skipping major version suffixes such as "/v2")
+func importedPackageNames(file *ast.File) []string {
+	var names []string
+	for _, imp := range file.Imports {
+		if imp.Name != nil {
+			if imp.Name.Name != "_" && imp.Name.Name != "." {
+				names = append(names, imp.Name.Name)
+			}
+			continue
+		}
+		names = append(names, defaultImportName(imp))
+	}
+	return names
+}
+
+// defaultImportName returns the name a package is imported under without an
+// explicit import name: the last import path element, skipping major version
+// suffixes such as "/v2"
+func defaultImportName(imp *ast.ImportSpec) string {
+	path := strings.Trim(imp.Path.Value, `"`)
+	parts := strings.Split(path, "/")
+	name := parts[len(parts)-1]
+	if len(parts) > 1 && isMajorVersionSuffix(name) {
+		name = parts[len(parts)-2]
+	}
+	return name
+}
+
+// packageUnderTestImport returns the name under which an external test file
+// imports the package under test (pkgName), or "" if it is not imported by
+// name (e.g. dot imports, whose calls already look like in-package calls)
+func packageUnderTestImport(file *ast.File, pkgName string) string {
+	for _, imp := range file.Imports {
+		if defaultImportName(imp) != pkgName {
+			continue
+		}
+		if imp.Name == nil {
+			return pkgName
+		}
+		if imp.Name.Name != "_" && imp.Name.Name != "." {
+			return imp.Name.Name
+		}
+	}
+	return ""
+}
+
+// trimPackageQualifier removes the pkg_ prefix from call names made through
+// the given import name, so calls from external test packages (foo.Bar())
+// match like calls from inside the package (Bar())
+func trimPackageQualifier(names []string, pkg string) []string {
+	if pkg == "" {
+		return names
+	}
+	for i, name := range names {
+		if rest, ok := strings.CutPrefix(name, pkg+"_"); ok {
+			names[i] = rest
+		}
+	}
+	return names
+}
+
+// isMajorVersionSuffix checks if an import path element is a major version suffix like "v2"
+func isMajorVersionSuffix(elem string) bool {
+	if len(elem) < 2 || elem[0] != 'v' {
+		return false
+	}
+	for _, r := range elem[1:] {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// packageScope returns the scope in which syntax-only call names are matched:
+// the directory of the file plus its package clause, with the _test suffix of
+// external test packages removed. It returns "" if the package name is unknown,
+// in which case names are matched globally.
+func packageScope(file, pkgName string) string {
+	if pkgName == "" {
+		return ""
+	}
+	return filepath.Dir(file) + ":" + strings.TrimSuffix(pkgName, "_test")
+}
+
+// importScope returns the scope for calls made through an imported package
+// name (pkg.Func), which match functions in any package with that name
+func importScope(pkgName string) string {
+	return "*:" + pkgName
+}
+
+// untypedPrefix marks the call names of tests parsed without type information
+// in a type-checked analysis, so that functions with a key can still be matched
+// against them by name
+const untypedPrefix = "untyped:"
+
+// scopedName prefixes a call name with its scope
+func scopedName(scope, name string) string {
+	if scope == "" {
+		return name
+	}
+	return scope + "#" + name
+}
+
+// addScopedNames adds the call names of a test to the set, scoped to the test's
+// package. Calls through imported package names are also added to the import scope.
+func addScopedNames(set map[string]bool, test TestInfo, names []string) {
+	scope := packageScope(test.File, test.PackageName)
+	add := func(name string) {
+		set[name] = true
+		if test.Untyped {
+			set[untypedPrefix+name] = true
+		}
+	}
+	for _, name := range names {
+		add(scopedName(scope, name))
+		if pkg, funcName, ok := strings.Cut(name, "_"); ok && slices.Contains(test.ImportedPackages, pkg) {
+			add(scopedName(importScope(pkg), funcName))
 		}
 	}
 }
@@ -135,61 +585,218 @@ func buildFuncInfo(funcDecl *ast.FuncDecl, funcName, relPath string, line int) F
 	}
 }
 
-// buildTestedFuncsMap creates a set of function names that are called from tests
+// propagateHelperCalls adds the functions called by test helpers to the called
+// functions of every test that (transitively) calls those helpers
+func propagateHelperCalls(fileTests map[string][]TestInfo, helpers []testHelper) {
+	if len(helpers) == 0 {
+		return
+	}
+	for _, tests := range fileTests {
+		for i := range tests {
+			expandHelperCalls(&tests[i], helpers)
+		}
+	}
+}
+
+// expandHelperCalls extends the calls of a test with the calls made by every
+// helper reachable from it
+func expandHelperCalls(test *TestInfo, helpers []testHelper) {
+	// Result types of constructor helpers, scoped by package
+	constructors := make(map[string]string)
+	for _, h := range helpers {
+		if h.Receiver == "" && h.resultType != "" {
+			constructors[scopedName(packageScope(h.File, h.PackageName), h.Name)] = h.resultType
+		}
+	}
+
+	expanded := make([]bool, len(helpers))
+	for changed := true; changed; {
+		changed = false
+		for i, h := range helpers {
+			if expanded[i] || !isHelperCalled(h, test, constructors) {
+				continue
+			}
+			expanded[i] = true
+			changed = true
+
+			test.CalledFuncs = appendMissing(test.CalledFuncs, h.calls.CalledFuncs)
+			test.CalledKeys = appendMissing(test.CalledKeys, h.calls.CalledKeys)
+			test.ReferencedFuncs = appendMissing(test.ReferencedFuncs, h.calls.ReferencedFuncs)
+			test.ReferencedKeys = appendMissing(test.ReferencedKeys, h.calls.ReferencedKeys)
+			test.InterfaceCalls = appendMissing(test.InterfaceCalls, h.calls.InterfaceCalls)
+			test.ConcreteTypes = appendMissing(test.ConcreteTypes, h.calls.ConcreteTypes)
+			for _, mc := range h.calls.MethodCalls {
+				if !slices.Contains(test.MethodCalls, mc) {
+					test.MethodCalls = append(test.MethodCalls, mc)
+				}
+			}
+		}
+	}
+}
+
+// isHelperCalled checks if a helper is among the functions called by a test.
+// Without type information, only helpers of the test's own package match,
+// like calls in matchCalledFunction, and method helpers match only calls on
+// variables of their receiver type (see extractMethodCalls). constructors maps
+// scoped constructor names to the type they return.
+func isHelperCalled(h testHelper, test *TestInfo, constructors map[string]string) bool {
+	if key := h.Key(); key != "" {
+		return slices.Contains(test.CalledKeys, key)
+	}
+
+	helperScope := packageScope(h.File, h.PackageName)
+	testScope := packageScope(test.File, test.PackageName)
+	if helperScope != "" && testScope != "" && helperScope != testScope {
+		return false
+	}
+
+	callName := h.Name
+	if h.Receiver != "" {
+		callName = h.Receiver + "_" + h.Name
+	}
+	if slices.Contains(test.CalledFuncs, callName) {
+		return true
+	}
+
+	if h.Receiver == "" {
+		return false
+	}
+	for _, mc := range test.MethodCalls {
+		if mc.Method != h.Name {
+			continue
+		}
+		recvType := mc.Type
+		if recvType == "" {
+			recvType = constructors[scopedName(testScope, mc.Constructor)]
+		}
+		if recvType == h.Receiver {
+			return true
+		}
+	}
+	return false
+}
+
+// appendMissing appends the elements of src that are not already in dst
+func appendMissing(dst, src []string) []string {
+	seen := make(map[string]bool, len(dst))
+	for _, s := range dst {
+		seen[s] = true
+	}
+	for _, s := range src {
+		if !seen[s] {
+			seen[s] = true
+			dst = append(dst, s)
+		}
+	}
+	return dst
+}
+
+// buildTestedFuncsMap creates a set of function names (scoped by package) and
+// qualified keys that are called from tests
 func buildTestedFuncsMap(fileTests map[string][]TestInfo) map[string]bool {
 	testedFuncs := make(map[string]bool)
 	for _, tests := range fileTests {
 		for _, test := range tests {
-			for _, calledFunc := range test.CalledFuncs {
-				testedFuncs[calledFunc] = true
+			addScopedNames(testedFuncs, test, test.CalledFuncs)
+			for _, key := range test.CalledKeys {
+				testedFuncs[key] = true
 			}
 		}
 	}
 	return testedFuncs
 }
 
-// findFunctionsWithoutTests returns functions that are not in the tested set
-// If coverageMap is provided, functions with >=50% coverage are considered adequately tested
-func findFunctionsWithoutTests(fileFunctions map[string][]FuncInfo, testedFuncs map[string]bool, coverageMap map[string]float64) []FuncInfo {
-	const coverageThreshold = 50.0 // Functions with >= 50% coverage are considered tested
-
-	var result []FuncInfo
-	for _, funcs := range fileFunctions {
-		for _, f := range funcs {
-			if !isFunctionTested(f, testedFuncs) {
-				// If we have coverage data, skip functions with >= 50% coverage
-				if coverageMap != nil {
-					if cov, exists := coverageMap[f.Name]; exists && cov >= coverageThreshold {
-						continue // Function has adequate coverage, skip it
-					}
-				}
-				result = append(result, f)
+// buildTestedFuncsMapsByStyle creates separate sets of functions called from
+// black-box tests (external test packages) and from white-box tests
+func buildTestedFuncsMapsByStyle(fileTests map[string][]TestInfo) (map[string]bool, map[string]bool) {
+	external := make(map[string][]TestInfo)
+	internal := make(map[string][]TestInfo)
+	for file, tests := range fileTests {
+		for _, test := range tests {
+			if test.External {
+				external[file] = append(external[file], test)
+			} else {
+				internal[file] = append(internal[file], test)
 			}
 		}
 	}
+	return buildTestedFuncsMap(external), buildTestedFuncsMap(internal)
+}
 
-	sort.Slice(result, func(i, j int) bool {
-		if result[i].File != result[j].File {
-			return result[i].File < result[j].File
+// buildReferencedFuncsMap creates a set of function names (scoped by package) and
+// qualified keys that are referenced from tests
+func buildReferencedFuncsMap(fileTests map[string][]TestInfo) map[string]bool {
+	referencedFuncs := make(map[string]bool)
+	for _, tests := range fileTests {
+		for _, test := range tests {
+			addScopedNames(referencedFuncs, test, test.ReferencedFuncs)
+			for _, key := range test.ReferencedKeys {
+				referencedFuncs[key] = true
+			}
 		}
-		return result[i].Line < result[j].Line
-	})
+	}
+	return referencedFuncs
+}
 
+// findFunctionsWithoutTests returns functions that are not in the tested set
+// If coverageMap is provided, functions with >=50% coverage are considered adequately tested
+func findFunctionsWithoutTests(fileFunctions map[string][]FuncInfo, testedFuncs map[string]bool, coverageMap map[funcPosition]float64) []FuncInfo {
+	var result []FuncInfo
+	for _, f := range classifyFunctions(fileFunctions, testEvidence{calledFuncs: testedFuncs, coverageMap: coverageMap}) {
+		if f.Verdict == VerdictUntested {
+			result = append(result, f)
+		}
+	}
 	return result
 }
 
 // isFunctionTested checks if a function is in the tested set
 func isFunctionTested(f FuncInfo, testedFuncs map[string]bool) bool {
-	// Direct match by function name
-	if testedFuncs[f.Name] {
-		return true
-	}
+	verdict, _ := matchCalledFunction(f, testedFuncs)
+	return verdict != VerdictUntested
+}
 
-	// Match by receiver type + function name (e.g., autoScalingGroup_loadConfig)
-	if f.Receiver != "" {
-		key := f.Receiver + "_" + f.Name
+// matchCalledFunction checks how a function matches the tested set, returning
+// the verdict and the matched call name for heuristic matches
+func matchCalledFunction(f FuncInfo, testedFuncs map[string]bool) (Verdict, string) {
+	// With type information, calls were resolved to exact function objects
+	if key := f.Key(); key != "" {
 		if testedFuncs[key] {
-			return true
+			return VerdictDirectCall, ""
+		}
+		// Tests left out of the typed load (e.g. behind build tags) only recorded names
+		return matchCalledName(f, testedFuncs, untypedPrefix)
+	}
+	return matchCalledName(f, testedFuncs, "")
+}
+
+// matchCalledName matches a function by name against the calls of the tested
+// set recorded under marker ("" for all calls, untypedPrefix for the calls of
+// tests parsed without type information)
+func matchCalledName(f FuncInfo, testedFuncs map[string]bool, marker string) (Verdict, string) {
+	// Calls only match within the function's own package, or through its package name
+	scopes := []string{packageScope(f.File, f.PackageName)}
+	if f.PackageName != "" {
+		scopes = append(scopes, importScope(f.PackageName))
+	}
+
+	// Function literals in variable fields (Var.Field) are called as Var_Field
+	callName := strings.Replace(f.Name, ".", "_", 1)
+
+	for _, scope := range scopes {
+		prefix := marker + scopedName(scope, "")
+
+		// Direct match by function name
+		if testedFuncs[prefix+callName] {
+			return VerdictDirectCall, ""
+		}
+
+		// Match by receiver type + function name (e.g., autoScalingGroup_loadConfig)
+		if f.Receiver != "" {
+			key := f.Receiver + "_" + f.Name
+			if testedFuncs[prefix+key] {
+				return VerdictReceiverMatch, key
+			}
 		}
 	}
 
@@ -197,13 +804,35 @@ func isFunctionTested(f FuncInfo, testedFuncs map[string]bool) bool {
 	// This handles cases where the variable name differs from the type name
 	// e.g., asg.loadConfig() is extracted as "asg_loadConfig" but the receiver type is "autoScalingGroup"
 	suffix := "_" + f.Name
-	for calledFunc := range testedFuncs {
-		if strings.HasSuffix(calledFunc, suffix) {
-			return true
+	var matched []string
+	for _, scope := range scopes {
+		prefix := marker + scopedName(scope, "")
+		for calledFunc := range testedFuncs {
+			if strings.HasPrefix(calledFunc, prefix) && strings.HasSuffix(calledFunc, suffix) {
+				matched = append(matched, strings.TrimPrefix(calledFunc, prefix))
+			}
 		}
 	}
+	if len(matched) > 0 {
+		return VerdictSuffixHeuristic, slices.Min(matched)
+	}
 
-	return false
+	return VerdictUntested, ""
+}
+
+// sortedTests returns the tests of all files, sorted by file and line
+func sortedTests(fileTests map[string][]TestInfo) []TestInfo {
+	var tests []TestInfo
+	for _, fileTests := range fileTests {
+		tests = append(tests, fileTests...)
+	}
+	sort.Slice(tests, func(i, j int) bool {
+		if tests[i].File != tests[j].File {
+			return tests[i].File < tests[j].File
+		}
+		return tests[i].Line < tests[j].Line
+	})
+	return tests
 }
 
 // findMisplacedTests finds tests that are in the wrong file
@@ -215,7 +844,8 @@ func findMisplacedTests(fileTests map[string][]TestInfo, fileFunctions map[strin
 
 	for testFile, tests := range fileTests {
 		for _, test := range tests {
-			if misplaced := checkTestPlacement(test, testFile, fileFunctions, properlyTestedFuncs); misplaced != nil {
+			misplaced := checkTestPlacement(test, testFile, fileFunctions, properlyTestedFuncs)
+			if misplaced != nil && fitsTestFile(test, fileTests[misplaced.ExpectedFile]) {
 				result = append(result, *misplaced)
 			}
 		}
@@ -231,6 +861,13 @@ func findMisplacedTests(fileTests map[string][]TestInfo, fileFunctions map[strin
 	return result
 }
 
+// fitsTestFile reports whether a test can be moved to a test file with the given
+// tests: black-box tests (package foo_test) and white-box tests (package foo)
+// cannot share a file
+func fitsTestFile(test TestInfo, tests []TestInfo) bool {
+	return len(tests) == 0 || tests[0].PackageName == test.PackageName
+}
+
 // buildProperlyTestedFuncsMap returns a set of function names that have tests in the correct file
 func buildProperlyTestedFuncsMap(fileTests map[string][]TestInfo, fileFunctions map[string][]FuncInfo) map[string]bool {
 	properlyTested := make(map[string]bool)
@@ -276,6 +913,11 @@ func checkTestPlacement(test TestInfo, testFile string, fileFunctions map[string
 		return nil
 	}
 
+	// Tests left out of the typed load have build constraints the expected file may lack
+	if test.Untyped {
+		return nil
+	}
+
 	// First, try to find the function under test by naming convention
 	// TestFoo -> Foo, TestFoo_SubTest -> Foo, Test_Foo -> Foo
 	primarySource := findSourceByTestName(test.Name, test.CalledFuncs, fileFunctions)
@@ -581,9 +1223,248 @@ func extractCalledFunctions(funcDecl *ast.FuncDecl) []string {
 	return calledFuncs
 }
 
+// extractMethodCalls returns the methods called on receivers, parameters and
+// local variables whose type is known from their declaration: a type
+// (f *fixture, var f fixture), a composite literal (f := &fixture{}), new(fixture),
+// or the result of a function call (f := newFixture(t)), recorded as constructor
+func extractMethodCalls(funcDecl *ast.FuncDecl) []MethodCall {
+	if funcDecl.Body == nil {
+		return nil
+	}
+
+	vars := make(map[string]MethodCall)
+	bindFields := func(fields *ast.FieldList) {
+		if fields == nil {
+			return
+		}
+		for _, field := range fields.List {
+			if typeName := getReceiverType(field.Type); typeName != "" {
+				for _, name := range field.Names {
+					vars[name.Name] = MethodCall{Type: typeName}
+				}
+			}
+		}
+	}
+	bind := func(name *ast.Ident, value ast.Expr) {
+		if name.Name == "_" {
+			return
+		}
+		if recv, ok := valueReceiverType(value); ok {
+			vars[name.Name] = recv
+		}
+	}
+
+	bindFields(funcDecl.Recv)
+	bindFields(funcDecl.Type.Params)
+	ast.Inspect(funcDecl.Body, func(n ast.Node) bool {
+		switch node := n.(type) {
+		case *ast.AssignStmt:
+			for i, lhs := range node.Lhs {
+				ident, ok := lhs.(*ast.Ident)
+				if !ok {
+					continue
+				}
+				switch {
+				case len(node.Rhs) == len(node.Lhs):
+					bind(ident, node.Rhs[i])
+				case i == 0 && len(node.Rhs) == 1:
+					// s, err := newServer(t)
+					bind(ident, node.Rhs[0])
+				}
+			}
+		case *ast.ValueSpec:
+			for i, name := range node.Names {
+				if node.Type != nil {
+					if typeName := getReceiverType(node.Type); typeName != "" && name.Name != "_" {
+						vars[name.Name] = MethodCall{Type: typeName}
+					}
+				} else if i < len(node.Values) {
+					bind(name, node.Values[i])
+				}
+			}
+		}
+		return true
+	})
+
+	var calls []MethodCall
+	ast.Inspect(funcDecl.Body, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		ident, ok := sel.X.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		if recv, ok := vars[ident.Name]; ok {
+			recv.Method = sel.Sel.Name
+			if !slices.Contains(calls, recv) {
+				calls = append(calls, recv)
+			}
+		}
+		return true
+	})
+	return calls
+}
+
+// valueReceiverType returns the type (or constructor) of a variable initialized
+// with value, if it can be told from the expression alone
+func valueReceiverType(value ast.Expr) (MethodCall, bool) {
+	value = ast.Unparen(value)
+	if unary, ok := value.(*ast.UnaryExpr); ok && unary.Op == token.AND {
+		value = ast.Unparen(unary.X)
+	}
+	switch v := value.(type) {
+	case *ast.CompositeLit:
+		if typeName := getReceiverType(v.Type); typeName != "" {
+			return MethodCall{Type: typeName}, true
+		}
+	case *ast.CallExpr:
+		fn, ok := unwrapTypeArgs(v.Fun).(*ast.Ident)
+		if !ok {
+			break
+		}
+		if fn.Name == "new" && len(v.Args) == 1 {
+			if typeName := getReceiverType(v.Args[0]); typeName != "" {
+				return MethodCall{Type: typeName}, true
+			}
+			break
+		}
+		return MethodCall{Constructor: fn.Name}, true
+	}
+	return MethodCall{}, false
+}
+
+// extractReferencedFunctions walks the AST of a function and extracts functions
+// used as values without being called: call arguments (sort.Slice(xs, less)),
+// composite literal elements ({fn: parseInt}) and assigned values (h := srv.Serve).
+// Identifiers declared in the function itself and predeclared constants such as
+// nil cannot name a function and are left out.
+func extractReferencedFunctions(funcDecl *ast.FuncDecl) []string {
+	if funcDecl.Body == nil {
+		return nil
+	}
+
+	locals := localNames(funcDecl)
+	seen := make(map[string]bool)
+	var referencedFuncs []string
+	add := func(expr ast.Expr) {
+		if ident, ok := ast.Unparen(expr).(*ast.Ident); ok && (locals[ident.Name] || isPredeclaredValue(ident.Name)) {
+			return
+		}
+		funcName := extractFuncNameFromExpr(expr)
+		if funcName != "" && !seen[funcName] {
+			seen[funcName] = true
+			referencedFuncs = append(referencedFuncs, funcName)
+		}
+	}
+
+	ast.Inspect(funcDecl.Body, func(n ast.Node) bool {
+		switch node := n.(type) {
+		case *ast.CallExpr:
+			for _, arg := range node.Args {
+				add(arg)
+			}
+		case *ast.CompositeLit:
+			for _, elt := range node.Elts {
+				if kv, ok := elt.(*ast.KeyValueExpr); ok {
+					elt = kv.Value
+				}
+				add(elt)
+			}
+		case *ast.AssignStmt:
+			for _, rhs := range node.Rhs {
+				add(rhs)
+			}
+		}
+		return true
+	})
+
+	return referencedFuncs
+}
+
+// localNames returns the names of the receiver, parameters, results, variables
+// and constants declared in a function, including those of its function literals.
+// Scopes are not tracked: a name declared anywhere in the function counts as local.
+func localNames(funcDecl *ast.FuncDecl) map[string]bool {
+	names := make(map[string]bool)
+	addFields := func(fields *ast.FieldList) {
+		if fields == nil {
+			return
+		}
+		for _, field := range fields.List {
+			for _, name := range field.Names {
+				names[name.Name] = true
+			}
+		}
+	}
+	addIdent := func(expr ast.Expr) {
+		if ident, ok := expr.(*ast.Ident); ok {
+			names[ident.Name] = true
+		}
+	}
+
+	addFields(funcDecl.Recv)
+	ast.Inspect(funcDecl, func(n ast.Node) bool {
+		switch node := n.(type) {
+		case *ast.FuncType:
+			addFields(node.Params)
+			addFields(node.Results)
+		case *ast.AssignStmt:
+			if node.Tok == token.DEFINE {
+				for _, lhs := range node.Lhs {
+					addIdent(lhs)
+				}
+			}
+		case *ast.RangeStmt:
+			if node.Tok == token.DEFINE {
+				addIdent(node.Key)
+				addIdent(node.Value)
+			}
+		case *ast.ValueSpec:
+			for _, name := range node.Names {
+				names[name.Name] = true
+			}
+		}
+		return true
+	})
+	delete(names, "_")
+	return names
+}
+
+// isPredeclaredValue reports whether name is a predeclared constant or nil
+func isPredeclaredValue(name string) bool {
+	switch name {
+	case "nil", "true", "false", "iota":
+		return true
+	}
+	return false
+}
+
+// extractFuncNameFromExpr extracts a function name from an identifier or
+// selector expression, using the same format as extractFuncNameFromCall.
+// Index expressions are not unwrapped: outside of call position, xs[i] is far
+// more likely an element than a generic instantiation.
+func extractFuncNameFromExpr(expr ast.Expr) string {
+	switch e := ast.Unparen(expr).(type) {
+	case *ast.Ident:
+		return e.Name
+	case *ast.SelectorExpr:
+		if ident, ok := e.X.(*ast.Ident); ok {
+			return ident.Name + "_" + e.Sel.Name
+		}
+		return e.Sel.Name
+	}
+	return ""
+}
+
 // extractFuncNameFromCall extracts the function name from a call expression
 func extractFuncNameFromCall(call *ast.CallExpr) string {
-	switch fn := call.Fun.(type) {
+	switch fn := unwrapTypeArgs(call.Fun).(type) {
 	case *ast.Ident:
 		// Direct function call: foo()
 		return fn.Name
@@ -599,3 +1480,15 @@ func extractFuncNameFromCall(call *ast.CallExpr) string {
 	}
 	return ""
 }
+
+// unwrapTypeArgs returns the generic function of an instantiation with
+// explicit type arguments (e.g. Map for Map[int, string]), or expr itself
+func unwrapTypeArgs(expr ast.Expr) ast.Expr {
+	switch e := expr.(type) {
+	case *ast.IndexExpr:
+		return e.X
+	case *ast.IndexListExpr:
+		return e.X
+	}
+	return expr
+}
@@ -4,8 +4,11 @@ import (
 	"go/ast"
 	"go/parser"
 	"go/token"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 )
@@ -153,6 +156,58 @@ func TestMixed(t *testing.T) {
 	}
 }
 
+func TestExtractReferencedFunctions(t *testing.T) {
+	code := `package test
+func TestRefs(t *testing.T) {
+	h := http.HandlerFunc(handleHealth)
+	sort.Slice(xs, lessByName)
+	sort.Slice(ys, lessBy[int])
+	process(items[0], handlers[name])
+	router.Handle("/x", srv.ServeUser)
+	cases := []struct{ fn func(string) int }{{fn: parseInt}}
+	_ = h
+	_ = cases
+	want := 3
+	check(t, want, nil, true)
+	for _, tc := range cases {
+		run(tc, func(x int) { use(x) })
+	}
+	notReferenced()
+}`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "test.go", code, 0)
+	if err != nil {
+		t.Fatalf("Failed to parse code: %v", err)
+	}
+
+	funcDecl := file.Decls[0].(*ast.FuncDecl)
+	got := make(map[string]bool)
+	for _, name := range extractReferencedFunctions(funcDecl) {
+		got[name] = true
+	}
+
+	for _, want := range []string{"handleHealth", "lessByName", "srv_ServeUser", "parseInt"} {
+		if !got[want] {
+			t.Errorf("extractReferencedFunctions() missing %q, got %v", want, got)
+		}
+	}
+	if got["notReferenced"] {
+		t.Error("Called functions should not be reported as referenced")
+	}
+	// Outside of call position, index expressions are elements, not instantiations
+	for _, name := range []string{"lessBy", "items", "handlers"} {
+		if got[name] {
+			t.Errorf("Index expression %s[...] should not be reported as referenced", name)
+		}
+	}
+	// Locals, parameters and predeclared constants cannot be functions under test
+	for _, name := range []string{"t", "h", "cases", "want", "tc", "x", "nil", "true"} {
+		if got[name] {
+			t.Errorf("Local or predeclared %s should not be reported as referenced", name)
+		}
+	}
+}
+
 func TestExtractFuncNameFromCall(t *testing.T) {
 	tests := []struct {
 		name string
@@ -184,6 +239,26 @@ func TestExtractFuncNameFromCall(t *testing.T) {
 			code: `package test; func f() { func(){}() }`,
 			want: "",
 		},
+		{
+			name: "generic function with one type argument",
+			code: `package test; func f() { Filter[int](xs, keep) }`,
+			want: "Filter",
+		},
+		{
+			name: "generic function with several type arguments",
+			code: `package test; func f() { Map[int, string](xs, f) }`,
+			want: "Map",
+		},
+		{
+			name: "generic package function",
+			code: `package test; func f() { cache.Get[User](key) }`,
+			want: "cache_Get",
+		},
+		{
+			name: "generic package function with several type arguments",
+			code: `package test; func f() { slicesx.Map[int, string](xs, f) }`,
+			want: "slicesx_Map",
+		},
 	}
 
 	for _, tt := range tests {
@@ -253,7 +328,7 @@ func TestPublicFunc(t *testing.T) {
 		t.Fatalf("Failed to write test file: %v", err)
 	}
 
-	result, err := analyzeProject(tmpDir, false, false, nil)
+	result, err := analyzeProject(tmpDir, analysisOptions{}, nil)
 	if err != nil {
 		t.Fatalf("analyzeProject failed: %v", err)
 	}
@@ -306,7 +381,7 @@ func TestPublicFunc(t *testing.T) {
 	}
 
 	t.Run("finds functions without tests", func(t *testing.T) {
-		result, err := analyzeProject(tmpDir, false, false, nil)
+		result, err := analyzeProject(tmpDir, analysisOptions{}, nil)
 		if err != nil {
 			t.Fatalf("analyzeProject failed: %v", err)
 		}
@@ -321,7 +396,7 @@ func TestPublicFunc(t *testing.T) {
 	})
 
 	t.Run("excludes private functions when flag set", func(t *testing.T) {
-		result, err := analyzeProject(tmpDir, true, false, nil)
+		result, err := analyzeProject(tmpDir, analysisOptions{excludePrivate: true}, nil)
 		if err != nil {
 			t.Fatalf("analyzeProject failed: %v", err)
 		}
@@ -379,7 +454,7 @@ func TestFuncB(t *testing.T) {
 		t.Fatalf("Failed to write test file: %v", err)
 	}
 
-	result, err := analyzeProject(tmpDir, false, false, nil)
+	result, err := analyzeProject(tmpDir, analysisOptions{}, nil)
 	if err != nil {
 		t.Fatalf("analyzeProject failed: %v", err)
 	}
@@ -433,7 +508,7 @@ func RegularFunc() {}
 		t.Fatalf("Failed to write source file: %v", err)
 	}
 
-	result, err := analyzeProject(tmpDir, false, false, nil)
+	result, err := analyzeProject(tmpDir, analysisOptions{}, nil)
 	if err != nil {
 		t.Fatalf("analyzeProject failed: %v", err)
 	}
@@ -670,6 +745,72 @@ func TestFindMisplacedTests(t *testing.T) {
 	}
 }
 
+func TestAnalyzeProject_MisplacedExternalTests(t *testing.T) {
+	files := map[string]string{
+		"store/store.go": "package store\n\nfunc Get() {}\n\nfunc Put() {}\n",
+		"store/conv.go":  "package store\n\nfunc Conv() {}\n",
+		"store/store_test.go": `package store
+
+import "testing"
+
+func TestGet(t *testing.T) {
+	Get()
+}
+`,
+		"store/conv_test.go": `package store_test
+
+import (
+	"testing"
+
+	"example.com/proj/store"
+)
+
+func TestConvEmpty(t *testing.T) {
+	store.Conv()
+}
+`,
+		"store/api_test.go": `package store_test
+
+import (
+	"testing"
+
+	"example.com/proj/store"
+)
+
+func TestPut(t *testing.T) {
+	store.Put()
+}
+
+func TestConv(t *testing.T) {
+	store.Conv()
+}
+`,
+	}
+
+	for _, mode := range []string{"typed", "syntax"} {
+		t.Run(mode, func(t *testing.T) {
+			projectFiles := maps.Clone(files)
+			if mode == "typed" {
+				projectFiles["go.mod"] = "module example.com/proj\n\ngo 1.21\n"
+			}
+			tmpDir := writeProjectFiles(t, projectFiles)
+
+			result, err := analyzeProject(tmpDir, analysisOptions{}, nil)
+			if err != nil {
+				t.Fatalf("analyzeProject failed: %v", err)
+			}
+
+			// TestPut cannot move to the white-box store_test.go
+			if len(result.MisplacedTests) != 1 {
+				t.Fatalf("Expected 1 misplaced test, got %v", result.MisplacedTests)
+			}
+			if got := result.MisplacedTests[0]; got.Test.Name != "TestConv" || got.ExpectedFile != filepath.Join("store", "conv_test.go") {
+				t.Errorf("Expected TestConv to belong in store/conv_test.go, got %s in %s", got.Test.Name, got.ExpectedFile)
+			}
+		})
+	}
+}
+
 func TestParseProjectFiles(t *testing.T) {
 	tmpDir, err := os.MkdirTemp("", "test-parse-*")
 	if err != nil {
@@ -721,12 +862,10 @@ func (b *Bar) Method() {}
 		t.Fatalf("Failed to parse: %v", err)
 	}
 
-	fileFunctions := make(map[string][]FuncInfo)
-	fileTests := make(map[string][]TestInfo)
+	result := newParseResult()
+	processFileDeclarations(file, fset, "test.go", false, false, result, nil)
 
-	processFileDeclarations(file, fset, "test.go", false, false, fileFunctions, fileTests)
-
-	funcs := fileFunctions["test.go"]
+	funcs := result.fileFunctions["test.go"]
 	if len(funcs) != 2 { // Foo and Bar.Method (init and main excluded)
 		t.Errorf("Expected 2 functions, got %d", len(funcs))
 		for _, f := range funcs {
@@ -735,6 +874,297 @@ func (b *Bar) Method() {}
 	}
 }
 
+func TestProcessFileDeclarations_Helpers(t *testing.T) {
+	code := `package testpkg
+func TestFoo(t *testing.T) { newServer(t) }
+func newServer(t *testing.T) *server { return startServer() }
+func (h *harness) assertUser(u string) { checkUser(u) }
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "foo_test.go", code, 0)
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+
+	result := newParseResult()
+	processFileDeclarations(file, fset, "foo_test.go", true, false, result, nil)
+
+	if len(result.fileTests["foo_test.go"]) != 1 {
+		t.Errorf("Expected 1 test, got %d", len(result.fileTests["foo_test.go"]))
+	}
+	if len(result.helpers) != 2 {
+		t.Fatalf("Expected 2 helpers, got %d", len(result.helpers))
+	}
+	if result.helpers[1].Receiver != "harness" || result.helpers[1].calls.CalledFuncs[0] != "checkUser" {
+		t.Errorf("Unexpected helper %+v", result.helpers[1])
+	}
+}
+
+func TestExpandHelperCalls(t *testing.T) {
+	helpers := []testHelper{
+		{FuncInfo: FuncInfo{Name: "newServer"}, calls: TestInfo{
+			CalledFuncs: []string{"startServer", "h_assertUser"},
+			MethodCalls: []MethodCall{{Method: "assertUser", Type: "harness"}},
+		}},
+		{FuncInfo: FuncInfo{Name: "assertUser", Receiver: "harness"}, calls: TestInfo{CalledFuncs: []string{"checkUser"}}},
+		{FuncInfo: FuncInfo{Name: "newFixture"}, resultType: "fixture"},
+		{FuncInfo: FuncInfo{Name: "Run", Receiver: "fixture"}, calls: TestInfo{CalledFuncs: []string{"runFixture"}}},
+		{FuncInfo: FuncInfo{Name: "Stop", Receiver: "fixture"}, calls: TestInfo{CalledFuncs: []string{"stopFixture"}}},
+		{FuncInfo: FuncInfo{Name: "unusedHelper"}, calls: TestInfo{CalledFuncs: []string{"neverCalled"}}},
+	}
+
+	tests := []struct {
+		name string
+		test TestInfo
+		want []string
+	}{
+		{
+			name: "receiver type of variable",
+			test: TestInfo{Name: "TestFoo", CalledFuncs: []string{"newServer"}},
+			want: []string{"newServer", "startServer", "h_assertUser", "checkUser"},
+		},
+		{
+			name: "receiver type from constructor",
+			test: TestInfo{
+				Name:        "TestRun",
+				CalledFuncs: []string{"newFixture", "f_Run"},
+				MethodCalls: []MethodCall{{Method: "Run", Constructor: "newFixture"}},
+			},
+			want: []string{"newFixture", "f_Run", "runFixture"},
+		},
+		{
+			name: "method of another type",
+			test: TestInfo{
+				Name:        "TestStop",
+				CalledFuncs: []string{"x_Stop"},
+				MethodCalls: []MethodCall{{Method: "Stop", Type: "server"}},
+			},
+			want: []string{"x_Stop"},
+		},
+		{
+			name: "method on variable of unknown type",
+			test: TestInfo{Name: "TestStop", CalledFuncs: []string{"x_Stop"}},
+			want: []string{"x_Stop"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expandHelperCalls(&tt.test, helpers)
+			if !slices.Equal(tt.test.CalledFuncs, tt.want) {
+				t.Errorf("expandHelperCalls() = %v, want %v", tt.test.CalledFuncs, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractMethodCalls(t *testing.T) {
+	code := `package testpkg
+func (h *harness) check(s *server, other pkg.Client) {
+	f := newFixture(t)
+	g, err := newFixture(t)
+	lit := &fixture{}
+	n := new(fixture)
+	var v store
+	var w = server{}
+	x := compute()
+	f.Run(); g.Run(); lit.Stop(); n.Stop(); v.Get(); w.Serve(); s.Serve(); h.assert()
+	other.Do(); x.Run(); unknown.Run(); f.Run()
+}
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "helpers_test.go", code, 0)
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+
+	got := extractMethodCalls(file.Decls[0].(*ast.FuncDecl))
+	want := []MethodCall{
+		{Method: "Run", Constructor: "newFixture"},
+		{Method: "Stop", Type: "fixture"},
+		{Method: "Get", Type: "store"},
+		{Method: "Serve", Type: "server"},
+		{Method: "assert", Type: "harness"},
+		{Method: "Run", Constructor: "compute"},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("extractMethodCalls() = %v, want %v", got, want)
+	}
+}
+
+func TestExpandHelperCalls_Keys(t *testing.T) {
+	helpers := []testHelper{
+		{
+			FuncInfo: FuncInfo{Name: "newServer", Package: "example.com/p"},
+			calls: TestInfo{
+				CalledKeys:     []string{"example.com/p.Start"},
+				InterfaceCalls: []string{"(example.com/p.Store).Get"},
+				ConcreteTypes:  []string{"example.com/p.memStore"},
+			},
+		},
+		{FuncInfo: FuncInfo{Name: "other", Package: "example.com/p"}, calls: TestInfo{CalledKeys: []string{"example.com/p.Other"}}},
+	}
+
+	test := TestInfo{Name: "TestFoo", CalledKeys: []string{"example.com/p.newServer"}}
+	expandHelperCalls(&test, helpers)
+	want := []string{"example.com/p.newServer", "example.com/p.Start"}
+	if strings.Join(test.CalledKeys, ",") != strings.Join(want, ",") {
+		t.Errorf("expandHelperCalls() keys = %v, want %v", test.CalledKeys, want)
+	}
+	if len(test.InterfaceCalls) != 1 || len(test.ConcreteTypes) != 1 {
+		t.Errorf("Expected interface calls and concrete types to be propagated, got %v and %v", test.InterfaceCalls, test.ConcreteTypes)
+	}
+}
+
+func TestAnalyzeProject_TestHelpers(t *testing.T) {
+	files := map[string]string{
+		"source.go": `package testpkg
+
+type server struct{}
+
+func NewServer() *server { return &server{} }
+
+func (s *server) Handle() {}
+
+func Unused() {}
+`,
+		"source_test.go": `package testpkg
+
+import "testing"
+
+func TestServer(t *testing.T) {
+	s := newTestServer(t)
+	exercise(s)
+}
+
+func newTestServer(t *testing.T) *server {
+	t.Helper()
+	return NewServer()
+}
+
+func exercise(s *server) {
+	s.Handle()
+}
+`,
+	}
+
+	for _, typed := range []bool{false, true} {
+		name := "syntax only"
+		if typed {
+			name = "type-checked"
+			files["go.mod"] = "module example.com/helpers\n\ngo 1.21\n"
+		}
+		t.Run(name, func(t *testing.T) {
+			tmpDir := writeProjectFiles(t, files)
+			result, err := analyzeProject(tmpDir, analysisOptions{}, nil)
+			if err != nil {
+				t.Fatalf("analyzeProject failed: %v", err)
+			}
+
+			if len(result.FunctionsWithoutTests) != 1 || result.FunctionsWithoutTests[0].Name != "Unused" {
+				t.Errorf("Expected only Unused without tests, got %v", result.FunctionsWithoutTests)
+			}
+		})
+	}
+}
+
+func TestAnalyzeProject_TestHelpersScopedByPackage(t *testing.T) {
+	// Both packages have a setup helper; each must only count for its own tests
+	tmpDir := writeProjectFiles(t, map[string]string{
+		"a/a.go": `package a
+
+func Start() {}
+
+func Stop() {}
+`,
+		"a/a_test.go": `package a
+
+import "testing"
+
+func TestA(t *testing.T) { setup() }
+
+func setup() { Start() }
+`,
+		"b/b.go": `package b
+
+func Start() {}
+
+func Stop() {}
+`,
+		"b/b_test.go": `package b
+
+import "testing"
+
+func TestB(t *testing.T) { setup() }
+
+func setup() { Stop() }
+`,
+	})
+
+	result, err := analyzeProject(tmpDir, analysisOptions{}, nil)
+	if err != nil {
+		t.Fatalf("analyzeProject failed: %v", err)
+	}
+
+	var untested []string
+	for _, f := range result.FunctionsWithoutTests {
+		untested = append(untested, f.File+":"+f.Name)
+	}
+	want := []string{filepath.Join("a", "a.go") + ":Stop", filepath.Join("b", "b.go") + ":Start"}
+	if !slices.Equal(untested, want) {
+		t.Errorf("FunctionsWithoutTests = %v, want %v", untested, want)
+	}
+}
+
+func TestAnalyzeProject_MethodHelpersMatchedByReceiverType(t *testing.T) {
+	tmpDir := writeProjectFiles(t, map[string]string{
+		"app.go": `package app
+
+type Runner struct{}
+
+func (r Runner) Run() {}
+
+func Start() {}
+
+func Stop() {}
+`,
+		"app_test.go": `package app
+
+import "testing"
+
+type fixture struct{}
+
+func newFixture(t *testing.T) *fixture { return &fixture{} }
+
+func (f *fixture) Run() { Start() }
+
+func (f *fixture) Close() { Stop() }
+
+func TestFixture(t *testing.T) {
+	fx := newFixture(t)
+	fx.Run()
+}
+
+func TestRunner(t *testing.T) {
+	var x Runner
+	x.Run()
+	y := Runner{}
+	y.Close()
+}
+`,
+	})
+
+	result, err := analyzeProject(tmpDir, analysisOptions{}, nil)
+	if err != nil {
+		t.Fatalf("analyzeProject failed: %v", err)
+	}
+
+	// Only fx.Run() is a call of the fixture helper; y.Close() is not
+	if len(result.FunctionsWithoutTests) != 1 || result.FunctionsWithoutTests[0].Name != "Stop" {
+		t.Errorf("Expected only Stop without tests, got %v", result.FunctionsWithoutTests)
+	}
+}
+
 func TestBuildFuncInfo(t *testing.T) {
 	code := `package test
 type MyType struct{}
@@ -997,3 +1427,791 @@ func TestCheckTestPlacement_NamingConvention(t *testing.T) {
 		t.Errorf("Test should not be misplaced, but got suggestion to move to %s", result.ExpectedFile)
 	}
 }
+
+func TestAnalyzeProject_TypeChecked(t *testing.T) {
+	tmpDir := writeProjectFiles(t, map[string]string{
+		"go.mod": "module example.com/typed\n\ngo 1.21\n",
+		"source.go": `package typed
+
+func Split(s string) []string { return []string{s} }
+
+type fileStore struct{}
+
+func (f *fileStore) Close() error { return nil }
+
+type memStore struct{}
+
+func (m *memStore) Close() error { return nil }
+
+type service struct{}
+
+func (s *service) Load() {}
+`,
+		"source_test.go": `package typed
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStores(t *testing.T) {
+	_ = strings.Split("a,b", ",")
+	st := &memStore{}
+	st.Close()
+	svc := &service{}
+	svc.Load()
+}
+`,
+	})
+
+	parsed, err := parseProjectFiles(tmpDir, false, false)
+	if err != nil {
+		t.Fatalf("parseProjectFiles failed: %v", err)
+	}
+	if !parsed.typed {
+		t.Fatal("Expected type-checked analysis for a module")
+	}
+
+	tests := parsed.fileTests["source_test.go"]
+	if len(tests) != 1 {
+		t.Fatalf("Expected 1 test, got %d", len(tests))
+	}
+	wantKeys := map[string]bool{
+		"(example.com/typed.memStore).Close": true,
+		"(example.com/typed.service).Load":   true,
+	}
+	for _, key := range tests[0].CalledKeys {
+		if !wantKeys[key] {
+			t.Errorf("Unexpected called key %q", key)
+		}
+		delete(wantKeys, key)
+	}
+	for key := range wantKeys {
+		t.Errorf("Missing called key %q", key)
+	}
+
+	result, err := analyzeProject(tmpDir, analysisOptions{}, nil)
+	if err != nil {
+		t.Fatalf("analyzeProject failed: %v", err)
+	}
+
+	// strings.Split must not mark our Split as tested, and calling memStore.Close
+	// must not mark fileStore.Close as tested
+	untested := make(map[string]bool)
+	for _, f := range result.FunctionsWithoutTests {
+		untested[f.Receiver+"."+f.Name] = true
+	}
+	if len(untested) != 2 || !untested[".Split"] || !untested["fileStore.Close"] {
+		t.Errorf("Expected Split and fileStore.Close without tests, got %v", untested)
+	}
+}
+
+func TestAnalyzeProject_ReferencedFunctions(t *testing.T) {
+	tmpDir := writeProjectFiles(t, map[string]string{
+		"go.mod": "module example.com/refs\n\ngo 1.21\n",
+		"server.go": `package refs
+
+type HandlerFunc func(path string)
+
+type Mux struct{ routes map[string]HandlerFunc }
+
+func (m *Mux) Handle(path string, h HandlerFunc) { m.routes[path] = h }
+
+type Server struct{}
+
+func (s *Server) ServeUser(path string) {}
+
+func handleHealth(path string) {}
+
+func lessByName(a, b string) bool { return a < b }
+
+func parseInt(s string) int { return 0 }
+
+func Unused() {}
+`,
+		"server_test.go": `package refs
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestServer(t *testing.T) {
+	srv := &Server{}
+	mux := &Mux{routes: map[string]HandlerFunc{}}
+	mux.Handle("/health", HandlerFunc(handleHealth))
+	mux.Handle("/user", srv.ServeUser)
+	xs := []string{"b", "a"}
+	sort.Slice(xs, func(i, j int) bool { return lessByName(xs[i], xs[j]) })
+	cases := []struct{ fn func(string) int }{{fn: parseInt}}
+	_ = cases
+}
+`,
+	})
+
+	result, err := analyzeProject(tmpDir, analysisOptions{}, nil)
+	if err != nil {
+		t.Fatalf("analyzeProject failed: %v", err)
+	}
+
+	if len(result.FunctionsWithoutTests) != 1 || result.FunctionsWithoutTests[0].Name != "Unused" {
+		t.Errorf("Expected only Unused without tests, got %v", result.FunctionsWithoutTests)
+	}
+
+	referenced := make(map[string]bool)
+	for _, f := range result.ReferencedOnlyFuncs {
+		referenced[f.Name] = true
+	}
+	// lessByName is called inside a closure, so it is a direct call
+	for _, want := range []string{"ServeUser", "handleHealth", "parseInt"} {
+		if !referenced[want] {
+			t.Errorf("Expected %s to be only referenced from tests, got %v", want, result.ReferencedOnlyFuncs)
+		}
+	}
+	if len(referenced) != 3 {
+		t.Errorf("Expected 3 referenced-only functions, got %v", result.ReferencedOnlyFuncs)
+	}
+}
+
+func TestAnalyzeProject_PackageScopedMatching(t *testing.T) {
+	// No go.mod, so matching is syntax-only and must be scoped by package
+	tmpDir := writeProjectFiles(t, map[string]string{
+		"a/a.go": "package a\n\nfunc New() {}\n",
+		"a/a_test.go": `package a
+
+import (
+	"testing"
+
+	"example.com/project/b"
+)
+
+func TestNew(t *testing.T) {
+	New()
+	b.Validate()
+}
+`,
+		"b/b.go": "package b\n\nfunc New() {}\n\nfunc Validate() {}\n",
+		"c/c.go": "package c\n\nfunc Exported() {}\n\nfunc Other() {}\n",
+		"c/external_test.go": `package c_test
+
+import (
+	"testing"
+
+	"example.com/project/c"
+)
+
+func TestExported(t *testing.T) {
+	c.Exported()
+}
+`,
+	})
+
+	result, err := analyzeProject(tmpDir, analysisOptions{}, nil)
+	if err != nil {
+		t.Fatalf("analyzeProject failed: %v", err)
+	}
+
+	untested := make(map[string]bool)
+	for _, f := range result.FunctionsWithoutTests {
+		untested[f.File+":"+f.Name] = true
+	}
+
+	want := map[string]bool{
+		filepath.Join("b", "b.go") + ":New":   true,
+		filepath.Join("c", "c.go") + ":Other": true,
+	}
+	if len(untested) != len(want) {
+		t.Errorf("Expected %v without tests, got %v", want, untested)
+	}
+	for key := range want {
+		if !untested[key] {
+			t.Errorf("Expected %s without tests, got %v", key, untested)
+		}
+	}
+}
+
+func TestImportedPackageNames(t *testing.T) {
+	code := `package foo_test
+
+import (
+	"testing"
+	_ "embed"
+	. "strings"
+	cfg "example.com/project/config"
+	"example.com/project/store/v2"
+)
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "foo_test.go", code, parser.ImportsOnly)
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+
+	got := importedPackageNames(file)
+	want := []string{"testing", "cfg", "store"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("importedPackageNames() = %v, want %v", got, want)
+	}
+}
+
+func TestPackageScope(t *testing.T) {
+	tests := []struct {
+		file    string
+		pkgName string
+		want    string
+	}{
+		{"foo.go", "", ""},
+		{"foo.go", "foo", ".:foo"},
+		{filepath.Join("pkg", "foo_test.go"), "foo_test", "pkg:foo"},
+	}
+
+	for _, tt := range tests {
+		if got := packageScope(tt.file, tt.pkgName); got != tt.want {
+			t.Errorf("packageScope(%q, %q) = %q, want %q", tt.file, tt.pkgName, got, tt.want)
+		}
+	}
+}
+
+// funcLitProject declares functions as package-level function literals
+var funcLitProject = map[string]string{
+	"server.go": `package srv
+
+type Server struct {
+	Handler func(path string) string
+	Close   func()
+}
+
+var parseHeader = func(h string) string { return h }
+
+var normalize = func(b []byte) []byte { return b }
+
+var unusedHook = func() {}
+
+var DefaultServer = &Server{
+	Handler: func(path string) string { return string(normalize([]byte(path))) },
+	Close:   func() {},
+}
+
+var Timeout = 30
+`,
+	"server_test.go": `package srv
+
+import "testing"
+
+func TestServer(t *testing.T) {
+	parseHeader("x")
+	DefaultServer.Handler("/")
+	parseHeader = func(h string) string { return "" }
+	unusedHook = nil
+}
+
+func TestClose(t *testing.T) {
+	stop := DefaultServer.Close
+	_ = stop
+}
+`,
+}
+
+func TestAnalyzeProject_FuncLitUnits(t *testing.T) {
+	for _, mode := range []string{"typed", "syntax"} {
+		t.Run(mode, func(t *testing.T) {
+			files := maps.Clone(funcLitProject)
+			if mode == "typed" {
+				files["go.mod"] = "module example.com/srv\n\ngo 1.21\n"
+			}
+			tmpDir := writeProjectFiles(t, files)
+
+			result, err := analyzeProject(tmpDir, analysisOptions{}, nil)
+			if err != nil {
+				t.Fatalf("analyzeProject failed: %v", err)
+			}
+
+			verdicts := make(map[string]Verdict)
+			for _, f := range result.Functions {
+				verdicts[f.Name] = f.Verdict
+			}
+			want := map[string]Verdict{
+				"parseHeader":           VerdictDirectCall,
+				"normalize":             VerdictUntested,
+				"unusedHook":            VerdictUntested,
+				"DefaultServer.Handler": VerdictDirectCall,
+				"DefaultServer.Close":   VerdictReferenced,
+			}
+			if len(verdicts) != len(want) {
+				t.Errorf("Expected %d functions, got %v", len(want), verdicts)
+			}
+			for name, verdict := range want {
+				if verdicts[name] != verdict {
+					t.Errorf("%s: expected verdict %s, got %s", name, verdict, verdicts[name])
+				}
+			}
+		})
+	}
+}
+
+func TestExtractFuncLitUnits(t *testing.T) {
+	src := `package p
+
+var (
+	a      = func() {}
+	b, c   = func() {}, 1
+	_      = func() {}
+	d      = (func() {})
+	s      = Server{Handler: func() {}, Name: "x"}
+	ptr    = &Server{Handler: func() {}}
+	n      = 5
+	e, f   = pair()
+)
+
+const k = 1
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+
+	var names []string
+	for _, decl := range file.Decls {
+		if genDecl, ok := decl.(*ast.GenDecl); ok {
+			for _, unit := range extractFuncLitUnits(genDecl) {
+				names = append(names, unit.name)
+			}
+		}
+	}
+
+	want := []string{"a", "b", "d", "s.Handler", "ptr.Handler"}
+	if !slices.Equal(names, want) {
+		t.Errorf("extractFuncLitUnits() = %v, want %v", names, want)
+	}
+}
+
+func TestIsExportedUnit(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"ParseHeader", true},
+		{"parseHeader", false},
+		{"DefaultServer.Handler", true},
+		{"DefaultServer.handler", false},
+		{"defaultServer.Handler", false},
+	}
+
+	for _, tt := range tests {
+		if got := isExportedUnit(tt.name); got != tt.want {
+			t.Errorf("isExportedUnit(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAnalyzeProject_GenericCalls(t *testing.T) {
+	files := map[string]string{
+		"gen.go": `package gen
+
+func Map[T, U any](xs []T, f func(T) U) []U {
+	var out []U
+	for _, x := range xs {
+		out = append(out, f(x))
+	}
+	return out
+}
+
+func Filter[T any](xs []T, keep func(T) bool) []T { return xs }
+`,
+		"cache/cache.go": `package cache
+
+func Get[T any](key string) T {
+	var zero T
+	return zero
+}
+
+func Put[K comparable, V any](key K, value V) {}
+`,
+		"gen_test.go": `package gen
+
+import (
+	"strconv"
+	"testing"
+
+	"example.com/gen/cache"
+)
+
+func TestGeneric(t *testing.T) {
+	_ = Map[int, string]([]int{1}, strconv.Itoa)
+	_ = cache.Get[string]("k")
+	cache.Put[string, int]("k", 1)
+}
+`,
+	}
+
+	for _, mode := range []string{"typed", "syntax"} {
+		t.Run(mode, func(t *testing.T) {
+			projectFiles := maps.Clone(files)
+			if mode == "typed" {
+				projectFiles["go.mod"] = "module example.com/gen\n\ngo 1.21\n"
+			}
+			tmpDir := writeProjectFiles(t, projectFiles)
+
+			result, err := analyzeProject(tmpDir, analysisOptions{}, nil)
+			if err != nil {
+				t.Fatalf("analyzeProject failed: %v", err)
+			}
+
+			if len(result.FunctionsWithoutTests) != 1 || result.FunctionsWithoutTests[0].Name != "Filter" {
+				t.Errorf("Expected only Filter without tests, got %v", result.FunctionsWithoutTests)
+			}
+			for _, f := range result.Functions {
+				if f.Name != "Filter" && f.Verdict != VerdictDirectCall {
+					t.Errorf("%s: expected verdict %s, got %s", f.Name, VerdictDirectCall, f.Verdict)
+				}
+			}
+		})
+	}
+}
+
+// externalTestProject has black-box tests in package api_test next to white-box tests
+var externalTestProject = map[string]string{
+	"api/api.go": `package api
+
+type Client struct{}
+
+func (c *Client) Do() {}
+
+func Parse() {}
+
+func Format() {}
+
+func Validate() {}
+
+func parseHeader(h string) string { return h }
+`,
+	"api/codec.go": `package api
+
+func Encode() {}
+
+func Decode() {}
+`,
+	"api/export_test.go": `package api
+
+var ParseHeader = parseHeader
+`,
+	"api/api_test.go": `package api_test
+
+import (
+	"testing"
+
+	"example.com/proj/api"
+)
+
+func TestParse(t *testing.T) {
+	api.Parse()
+	c := &api.Client{}
+	c.Do()
+	api.ParseHeader("x")
+}
+
+func TestRoundTrip(t *testing.T) {
+	api.Encode()
+	api.Decode()
+}
+`,
+	"api/internal_test.go": `package api
+
+import "testing"
+
+func TestInternal(t *testing.T) {
+	Format()
+	Parse()
+}
+`,
+}
+
+func TestAnalyzeProject_ExternalTestPackages(t *testing.T) {
+	for _, mode := range []string{"typed", "syntax"} {
+		t.Run(mode, func(t *testing.T) {
+			files := maps.Clone(externalTestProject)
+			if mode == "typed" {
+				files["go.mod"] = "module example.com/proj\n\ngo 1.21\n"
+			}
+			tmpDir := writeProjectFiles(t, files)
+
+			result, err := analyzeProject(tmpDir, analysisOptions{requireBlackBox: true}, nil)
+			if err != nil {
+				t.Fatalf("analyzeProject failed: %v", err)
+			}
+
+			styles := make(map[string]TestStyle)
+			for _, f := range result.Functions {
+				styles[f.Name] = f.TestStyle
+			}
+			want := map[string]TestStyle{
+				"Parse":       TestStyleBoth,
+				"Format":      TestStyleWhiteBox,
+				"Validate":    TestStyleNone,
+				"Do":          TestStyleBlackBox,
+				"parseHeader": TestStyleBlackBox,
+				"Encode":      TestStyleBlackBox,
+			}
+			for name, style := range want {
+				if styles[name] != style {
+					t.Errorf("%s: expected test style %q, got %q", name, style, styles[name])
+				}
+			}
+
+			if len(result.FunctionsWithoutTests) != 1 || result.FunctionsWithoutTests[0].Name != "Validate" {
+				t.Errorf("Expected only Validate without tests, got %v", result.FunctionsWithoutTests)
+			}
+			if len(result.WhiteBoxOnlyFuncs) != 1 || result.WhiteBoxOnlyFuncs[0].Name != "Format" {
+				t.Errorf("Expected only Format without black-box tests, got %v", result.WhiteBoxOnlyFuncs)
+			}
+
+			// Calls through the package under test are attributed to its files
+			var roundTrip *MisplacedTest
+			for i, m := range result.MisplacedTests {
+				if m.Test.Name == "TestRoundTrip" {
+					roundTrip = &result.MisplacedTests[i]
+				}
+			}
+			if roundTrip == nil || roundTrip.ExpectedFile != filepath.Join("api", "codec_test.go") {
+				t.Errorf("Expected TestRoundTrip to belong in api/codec_test.go, got %v", result.MisplacedTests)
+			}
+		})
+	}
+}
+
+func TestAnalyzeProject_ExportAliasesOfOtherPackages(t *testing.T) {
+	files := map[string]string{
+		"a/a.go": `package a
+
+func Format() {}
+`,
+		"b/b.go": `package b
+
+func parse() {}
+`,
+		"b/export_test.go": `package b
+
+import "example.com/proj/a"
+
+var Parse = parse
+
+var FormatA = a.Format
+
+var Limit = maxLimit
+`,
+		"b/b_test.go": `package b_test
+
+import (
+	"testing"
+
+	"example.com/proj/b"
+)
+
+func TestB(t *testing.T) {
+	b.Parse()
+	b.FormatA()
+	_ = b.Limit
+}
+`,
+	}
+
+	for _, mode := range []string{"typed", "syntax"} {
+		t.Run(mode, func(t *testing.T) {
+			projectFiles := maps.Clone(files)
+			if mode == "typed" {
+				projectFiles["go.mod"] = "module example.com/proj\n\ngo 1.21\n"
+				projectFiles["b/limit_test.go"] = "package b\n\nconst maxLimit = 10\n"
+			}
+			tmpDir := writeProjectFiles(t, projectFiles)
+
+			result, err := analyzeProject(tmpDir, analysisOptions{}, nil)
+			if err != nil {
+				t.Fatalf("analyzeProject failed: %v", err)
+			}
+
+			// An alias of another package's function does not test it
+			if len(result.FunctionsWithoutTests) != 1 || result.FunctionsWithoutTests[0].Name != "Format" {
+				t.Errorf("Expected only Format without tests, got %v", result.FunctionsWithoutTests)
+			}
+		})
+	}
+}
+
+func TestAnalyzeProject_FilesExcludedByBuildConstraints(t *testing.T) {
+	tmpDir := writeProjectFiles(t, map[string]string{
+		"go.mod":    "module example.com/tags\n\ngo 1.21\n",
+		"source.go": "package tags\n\nfunc Foo() {}\n",
+		"source_plan9.go": `package tags
+
+func Bar() {}
+`,
+		"gen.go": `//go:build ignore
+
+package tags
+
+func Generate() {}
+`,
+		"source_test.go": `package tags
+
+import "testing"
+
+func TestFoo(t *testing.T) {
+	Foo()
+}
+`,
+		"source_plan9_test.go": `package tags
+
+import "testing"
+
+func TestBar(t *testing.T) {
+	Bar()
+}
+`,
+	})
+
+	result, err := analyzeProject(tmpDir, analysisOptions{}, nil)
+	if err != nil {
+		t.Fatalf("analyzeProject failed: %v", err)
+	}
+
+	// Files of other platforms and tags are analyzed without type information
+	if len(result.Functions) != 3 {
+		t.Errorf("Expected 3 functions, got %v", result.Functions)
+	}
+	if len(result.FunctionsWithoutTests) != 1 || result.FunctionsWithoutTests[0].Name != "Generate" {
+		t.Errorf("Expected only Generate without tests, got %v", result.FunctionsWithoutTests)
+	}
+}
+
+func TestAnalyzeProject_TaggedTestFiles(t *testing.T) {
+	tmpDir := writeProjectFiles(t, map[string]string{
+		"go.mod": "module example.com/tags\n\ngo 1.21\n",
+		"store.go": `package tags
+
+func Save() {}
+
+func Load() {}
+
+type Store struct{}
+
+func (s *Store) Close() {}
+`,
+		"store_test.go": `package tags
+
+import "testing"
+
+func TestLoad(t *testing.T) {
+	Load()
+}
+`,
+		"store_integration_test.go": `//go:build integration
+
+package tags
+
+import "testing"
+
+func TestSave(t *testing.T) {
+	Save()
+	s := &Store{}
+	s.Close()
+}
+`,
+	})
+
+	result, err := analyzeProject(tmpDir, analysisOptions{}, nil)
+	if err != nil {
+		t.Fatalf("analyzeProject failed: %v", err)
+	}
+
+	// Calls of tests behind build tags are matched by name
+	if len(result.FunctionsWithoutTests) != 0 {
+		t.Errorf("Expected all functions tested, got %v", result.FunctionsWithoutTests)
+	}
+	// store_test.go does not have the integration build tag
+	if len(result.MisplacedTests) != 0 {
+		t.Errorf("Expected no misplaced tests, got %v", result.MisplacedTests)
+	}
+}
+
+func TestDropForeignAliases(t *testing.T) {
+	fileFunctions := map[string][]FuncInfo{
+		filepath.Join("a", "a.go"): {
+			{Name: "parse", File: filepath.Join("a", "a.go"), PackageName: "a"},
+			{Name: "Handle", Receiver: "Server", File: filepath.Join("a", "a.go"), PackageName: "a"},
+			{Name: "DefaultServer.Handler", File: filepath.Join("a", "a.go"), PackageName: "a"},
+		},
+		filepath.Join("b", "b.go"): {
+			{Name: "format", File: filepath.Join("b", "b.go"), PackageName: "b"},
+		},
+	}
+	alias := func(name, aliasOf string) testHelper {
+		return testHelper{FuncInfo: FuncInfo{Name: name, File: filepath.Join("a", "export_test.go"), PackageName: "a"}, aliasOf: aliasOf}
+	}
+	helpers := []testHelper{
+		alias("Parse", "parse"),
+		alias("Handle", "Server_Handle"),
+		alias("Handler", "DefaultServer_Handler"),
+		alias("Format", "format"),
+		alias("Limit", "maxLimit"),
+		{FuncInfo: FuncInfo{Name: "setup", File: filepath.Join("a", "a_test.go"), PackageName: "a"}},
+	}
+
+	var kept []string
+	for _, h := range dropForeignAliases(helpers, fileFunctions) {
+		kept = append(kept, h.Name)
+	}
+	if want := []string{"Parse", "Handle", "Handler", "setup"}; !slices.Equal(kept, want) {
+		t.Errorf("dropForeignAliases() kept %v, want %v", kept, want)
+	}
+}
+
+func TestPackageUnderTestImport(t *testing.T) {
+	tests := []struct {
+		name    string
+		imports string
+		want    string
+	}{
+		{"plain import", `"example.com/proj/api"`, "api"},
+		{"major version", `"example.com/proj/api/v2"`, "api"},
+		{"aliased import", `a "example.com/proj/api"`, "a"},
+		{"dot import", `. "example.com/proj/api"`, ""},
+		{"not imported", `"example.com/proj/other"`, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := "package api_test\n\nimport " + tt.imports + "\n"
+			file, err := parser.ParseFile(token.NewFileSet(), "api_test.go", src, parser.ImportsOnly)
+			if err != nil {
+				t.Fatalf("Failed to parse: %v", err)
+			}
+			if got := packageUnderTestImport(file, "api"); got != tt.want {
+				t.Errorf("packageUnderTestImport() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrimPackageQualifier(t *testing.T) {
+	got := trimPackageQualifier([]string{"api_Parse", "c_Do", "Format", "other_Func"}, "api")
+	want := []string{"Parse", "c_Do", "Format", "other_Func"}
+	if !slices.Equal(got, want) {
+		t.Errorf("trimPackageQualifier() = %v, want %v", got, want)
+	}
+}
+
+func TestSortedTests(t *testing.T) {
+	fileTests := map[string][]TestInfo{
+		"b_test.go": {{Name: "TestB", File: "b_test.go", Line: 3}},
+		"a_test.go": {{Name: "TestA2", File: "a_test.go", Line: 9}, {Name: "TestA1", File: "a_test.go", Line: 4}},
+	}
+
+	var got []string
+	for _, test := range sortedTests(fileTests) {
+		got = append(got, test.Name)
+	}
+	if want := []string{"TestA1", "TestA2", "TestB"}; !slices.Equal(got, want) {
+		t.Errorf("sortedTests() = %v, want %v", got, want)
+	}
+}
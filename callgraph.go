@@ -0,0 +1,252 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/callgraph"
+	"golang.org/x/tools/go/callgraph/cha"
+	"golang.org/x/tools/go/callgraph/rta"
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/go/ssa"
+)
+
+// Supported call graph algorithms
+const (
+	callGraphCHA = "cha"
+	callGraphRTA = "rta"
+)
+
+// reachStep records how a function was first reached during the traversal
+type reachStep struct {
+	depth int
+	prev  *ssa.Function // caller on the shortest path, nil for test roots
+}
+
+// buildReachabilityChains builds a static call graph of the loaded program and
+// returns, for every module function reachable from a test root, the shortest
+// call chain leading to it (e.g. [TestX, A, B, Foo]), keyed by the function's
+// qualified key. maxDepth limits the number of calls followed from a root
+// (0 for unlimited).
+func buildReachabilityChains(pkgs []*packages.Package, algorithm string, maxDepth int) (map[string][]string, error) {
+	prog, ssaPkgs := buildSSAProgram(pkgs)
+	roots := findTestRoots(prog, ssaPkgs)
+	if len(roots) == 0 {
+		return nil, nil
+	}
+
+	var cg *callgraph.Graph
+	switch algorithm {
+	case callGraphCHA:
+		cg = cha.CallGraph(prog)
+	case callGraphRTA:
+		rtaRoots := roots
+		for _, p := range ssaPkgs {
+			if init := p.Func("init"); init != nil {
+				rtaRoots = append(rtaRoots, init)
+			}
+		}
+		cg = rta.Analyze(rtaRoots, true).CallGraph
+	default:
+		return nil, fmt.Errorf("unknown call graph algorithm %q (want %q or %q)", algorithm, callGraphCHA, callGraphRTA)
+	}
+
+	modulePkgs := make(map[string]bool)
+	for _, pkg := range pkgs {
+		modulePkgs[pkg.PkgPath] = true
+	}
+
+	funcLits := collectFuncLitUnits(pkgs)
+	steps := traverseCallGraph(cg, roots, maxDepth)
+	chains := make(map[string][]string)
+	for fn, step := range steps {
+		if step.prev == nil || fn.Synthetic != "" {
+			continue
+		}
+		key, pkgPath := ssaFuncKey(fn, funcLits)
+		if key == "" || !modulePkgs[pkgPath] {
+			continue
+		}
+		chain := buildChain(fn, steps, funcLits)
+		if existing, ok := chains[key]; !ok || len(chain) < len(existing) {
+			chains[key] = chain
+		}
+	}
+
+	return chains, nil
+}
+
+// collectFuncLitUnits maps the positions of package-level function literal
+// units (see extractFuncLitUnits) to their names
+func collectFuncLitUnits(pkgs []*packages.Package) map[token.Pos]string {
+	funcLits := make(map[token.Pos]string)
+	for _, pkg := range pkgs {
+		for _, file := range pkg.Syntax {
+			for _, decl := range file.Decls {
+				if genDecl, ok := decl.(*ast.GenDecl); ok {
+					for _, unit := range extractFuncLitUnits(genDecl) {
+						funcLits[unit.lit.Pos()] = unit.name
+					}
+				}
+			}
+		}
+	}
+	return funcLits
+}
+
+// ssaFuncKey returns the qualified key and package path of a declared function
+// or package-level function literal unit, or empty strings for other functions
+// (e.g. nested closures)
+func ssaFuncKey(fn *ssa.Function, funcLits map[token.Pos]string) (string, string) {
+	if obj, ok := fn.Object().(*types.Func); ok && obj.Pkg() != nil {
+		return funcObjectKey(obj), obj.Pkg().Path()
+	}
+	if name, ok := funcLits[fn.Pos()]; ok && fn.Parent() != nil && fn.Pkg != nil {
+		pkgPath := fn.Pkg.Pkg.Path()
+		return qualifiedFuncKey(pkgPath, "", name), pkgPath
+	}
+	return "", ""
+}
+
+// buildSSAProgram creates and builds an SSA program for the well-typed loaded
+// packages. Dependencies are created from their type information only.
+func buildSSAProgram(pkgs []*packages.Package) (*ssa.Program, []*ssa.Package) {
+	prog := ssa.NewProgram(pkgs[0].Fset, ssa.InstantiateGenerics)
+
+	created := make(map[*types.Package]bool)
+	var ssaPkgs []*ssa.Package
+	for _, p := range pkgs {
+		if p.Types == nil || p.IllTyped || created[p.Types] {
+			continue
+		}
+		created[p.Types] = true
+		ssaPkgs = append(ssaPkgs, prog.CreatePackage(p.Types, p.Syntax, p.TypesInfo, true))
+	}
+
+	var createImports func(tp *types.Package)
+	createImports = func(tp *types.Package) {
+		for _, imp := range tp.Imports() {
+			if created[imp] {
+				continue
+			}
+			created[imp] = true
+			prog.CreatePackage(imp, nil, nil, true)
+			createImports(imp)
+		}
+	}
+	for _, p := range ssaPkgs {
+		createImports(p.Pkg)
+	}
+
+	prog.Build()
+	return prog, ssaPkgs
+}
+
+// findTestRoots returns the Test, Benchmark, Fuzz and Example functions
+// declared in _test.go files of the given packages
+func findTestRoots(prog *ssa.Program, ssaPkgs []*ssa.Package) []*ssa.Function {
+	var roots []*ssa.Function
+	for _, p := range ssaPkgs {
+		for _, member := range p.Members {
+			fn, ok := member.(*ssa.Function)
+			if !ok || !isTestFunction(fn.Name()) {
+				continue
+			}
+			if strings.HasSuffix(prog.Fset.Position(fn.Pos()).Filename, "_test.go") {
+				roots = append(roots, fn)
+			}
+		}
+	}
+
+	// Sort for deterministic traversal order
+	sort.Slice(roots, func(i, j int) bool {
+		return roots[i].String() < roots[j].String()
+	})
+	return roots
+}
+
+// traverseCallGraph performs a breadth-first traversal from all roots at once,
+// recording the depth and shortest-path caller of every reached function.
+// Calls made from closures are attributed to the enclosing function, since
+// closures are typically invoked through code without a body in the program
+// (e.g. t.Run).
+func traverseCallGraph(cg *callgraph.Graph, roots []*ssa.Function, maxDepth int) map[*ssa.Function]reachStep {
+	steps := make(map[*ssa.Function]reachStep)
+	queue := make([]*ssa.Function, 0, len(roots))
+	for _, root := range roots {
+		steps[root] = reachStep{}
+		queue = append(queue, root)
+	}
+
+	for len(queue) > 0 {
+		fn := queue[0]
+		queue = queue[1:]
+
+		depth := steps[fn].depth + 1
+		if maxDepth > 0 && depth > maxDepth {
+			continue
+		}
+
+		for _, caller := range withAnonFuncs(fn) {
+			node := cg.Nodes[caller]
+			if node == nil {
+				continue
+			}
+			for _, edge := range node.Out {
+				callee := edge.Callee.Func
+				if _, seen := steps[callee]; seen {
+					continue
+				}
+				steps[callee] = reachStep{depth: depth, prev: fn}
+				queue = append(queue, callee)
+			}
+		}
+	}
+
+	return steps
+}
+
+// withAnonFuncs returns fn followed by all closures nested within it
+func withAnonFuncs(fn *ssa.Function) []*ssa.Function {
+	funcs := []*ssa.Function{fn}
+	for i := 0; i < len(funcs); i++ {
+		funcs = append(funcs, funcs[i].AnonFuncs...)
+	}
+	return funcs
+}
+
+// buildChain reconstructs the call chain from a test root to fn,
+// omitting synthetic wrapper functions
+func buildChain(fn *ssa.Function, steps map[*ssa.Function]reachStep, funcLits map[token.Pos]string) []string {
+	var chain []string
+	for cur := fn; cur != nil; cur = steps[cur].prev {
+		if cur.Synthetic != "" {
+			continue
+		}
+		chain = append(chain, ssaFuncDisplayName(cur, funcLits))
+	}
+
+	for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
+		chain[i], chain[j] = chain[j], chain[i]
+	}
+	return chain
+}
+
+// ssaFuncDisplayName returns the report name of an SSA function, e.g. "Foo",
+// "(Type).Method" or "parseHeader" for a function literal unit
+func ssaFuncDisplayName(fn *ssa.Function, funcLits map[token.Pos]string) string {
+	if obj, ok := fn.Object().(*types.Func); ok {
+		if recv := funcRecvTypeName(obj); recv != "" {
+			return fmt.Sprintf("(%s).%s", recv, obj.Name())
+		}
+		return obj.Name()
+	}
+	if name, ok := funcLits[fn.Pos()]; ok && fn.Parent() != nil {
+		return name
+	}
+	return fn.Name()
+}
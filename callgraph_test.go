@@ -0,0 +1,149 @@
+package main
+
+import (
+	"maps"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+// callChainProject is a module where Foo is only reached from a test through A and B
+var callChainProject = map[string]string{
+	"go.mod": "module example.com/chain\n\ngo 1.21\n",
+	"chain.go": `package chain
+
+type Store interface {
+	Save() error
+}
+
+type memStore struct{}
+
+func (m *memStore) Save() error { return nil }
+
+func A() { B() }
+
+func B() { Foo() }
+
+func Foo() {}
+
+func Persist(s Store) error { return s.Save() }
+
+func Unused() {}
+`,
+	"chain_test.go": `package chain
+
+import "testing"
+
+func TestA(t *testing.T) {
+	t.Run("sub", func(t *testing.T) {
+		A()
+	})
+}
+
+func TestPersist(t *testing.T) {
+	Persist(&memStore{})
+}
+`,
+}
+
+func TestBuildReachabilityChains(t *testing.T) {
+	tmpDir := writeProjectFiles(t, callChainProject)
+	pkgs, err := loadTypedPackages(tmpDir)
+	if err != nil {
+		t.Fatalf("loadTypedPackages failed: %v", err)
+	}
+
+	for _, algorithm := range []string{callGraphCHA, callGraphRTA} {
+		t.Run(algorithm, func(t *testing.T) {
+			chains, err := buildReachabilityChains(pkgs, algorithm, 0)
+			if err != nil {
+				t.Fatalf("buildReachabilityChains failed: %v", err)
+			}
+
+			want := []string{"TestA", "A", "B", "Foo"}
+			if got := chains["example.com/chain.Foo"]; !reflect.DeepEqual(got, want) {
+				t.Errorf("chain for Foo = %v, want %v", got, want)
+			}
+
+			// Reached through interface dispatch in Persist
+			want = []string{"TestPersist", "Persist", "(memStore).Save"}
+			if got := chains["(example.com/chain.memStore).Save"]; !reflect.DeepEqual(got, want) {
+				t.Errorf("chain for memStore.Save = %v, want %v", got, want)
+			}
+
+			if _, ok := chains["example.com/chain.Unused"]; ok {
+				t.Error("Unused should not be reachable from tests")
+			}
+		})
+	}
+}
+
+func TestBuildReachabilityChains_MaxDepth(t *testing.T) {
+	tmpDir := writeProjectFiles(t, callChainProject)
+	pkgs, err := loadTypedPackages(tmpDir)
+	if err != nil {
+		t.Fatalf("loadTypedPackages failed: %v", err)
+	}
+
+	chains, err := buildReachabilityChains(pkgs, callGraphCHA, 2)
+	if err != nil {
+		t.Fatalf("buildReachabilityChains failed: %v", err)
+	}
+
+	if _, ok := chains["example.com/chain.B"]; !ok {
+		t.Error("B should be reachable within depth 2")
+	}
+	if _, ok := chains["example.com/chain.Foo"]; ok {
+		t.Error("Foo should not be reachable within depth 2")
+	}
+}
+
+func TestBuildReachabilityChains_UnknownAlgorithm(t *testing.T) {
+	tmpDir := writeProjectFiles(t, callChainProject)
+	pkgs, err := loadTypedPackages(tmpDir)
+	if err != nil {
+		t.Fatalf("loadTypedPackages failed: %v", err)
+	}
+
+	if _, err := buildReachabilityChains(pkgs, "vta", 0); err == nil {
+		t.Error("Expected error for unknown algorithm, got nil")
+	}
+}
+
+func TestAnalyzeProject_CallGraph(t *testing.T) {
+	tmpDir := writeProjectFiles(t, callChainProject)
+
+	result, err := analyzeProject(tmpDir, analysisOptions{callGraph: callGraphCHA}, nil)
+	if err != nil {
+		t.Fatalf("analyzeProject failed: %v", err)
+	}
+
+	if len(result.FunctionsWithoutTests) != 1 || result.FunctionsWithoutTests[0].Name != "Unused" {
+		t.Errorf("Expected only Unused without tests, got %v", result.FunctionsWithoutTests)
+	}
+
+	// A and Persist are called directly; B, Foo and memStore.Save only indirectly
+	if len(result.IndirectlyTestedFuncs) != 3 {
+		t.Errorf("Expected 3 indirectly tested functions, got %v", result.IndirectlyTestedFuncs)
+	}
+}
+
+func TestAnalyzeProject_FuncLitUnitsCallGraph(t *testing.T) {
+	files := maps.Clone(funcLitProject)
+	files["go.mod"] = "module example.com/srv\n\ngo 1.21\n"
+	tmpDir := writeProjectFiles(t, files)
+
+	result, err := analyzeProject(tmpDir, analysisOptions{callGraph: callGraphCHA}, nil)
+	if err != nil {
+		t.Fatalf("analyzeProject failed: %v", err)
+	}
+
+	if len(result.IndirectlyTestedFuncs) != 1 {
+		t.Fatalf("Expected 1 indirectly tested function, got %v", result.IndirectlyTestedFuncs)
+	}
+	reached := result.IndirectlyTestedFuncs[0]
+	wantChain := []string{"TestServer", "DefaultServer.Handler", "normalize"}
+	if reached.Func.Name != "normalize" || !slices.Equal(reached.Chain, wantChain) {
+		t.Errorf("Expected normalize via %v, got %s via %v", wantChain, reached.Func.Name, reached.Chain)
+	}
+}
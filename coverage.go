@@ -1,20 +1,317 @@
 package main
 
 import (
-	"bufio"
 	"bytes"
+	"encoding/json"
 	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"maps"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
-	"regexp"
+	"runtime"
+	"slices"
 	"sort"
-	"strconv"
 	"strings"
+	"sync"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/tools/cover"
 )
 
-// analyzeCoverage runs go test with coverage and returns functions below the threshold
-func analyzeCoverage(dir string, threshold float64, verbose bool) ([]LowCoverageFunc, error) {
+// funcCoverage is the statement coverage of a single function, computed from
+// the coverage profile blocks within its declaration
+type funcCoverage struct {
+	File      string // path relative to the analyzed directory
+	Line      int
+	Name      string
+	Receiver  string
+	Coverage  float64
+	Uncovered []LineRange // lines of statements that were never executed
+
+	IntegrationOnly bool     // executed by integration runs (GOCOVERDIR) but by no unit test
+	Package         string   // import path of the function's package
+	CoveredBy       []string // import paths of the packages whose tests executed the function (only with -coverpkg)
+
+	// Lowest and highest execution counts of the function's blocks (only in count and atomic mode)
+	MinHits int
+	MaxHits int
+}
+
+// coverageData holds the function coverage of a single go test run. It is
+// collected once per invocation and shared by the "missing tests" filter and
+// the low coverage report.
+type coverageData struct {
+	mode     string // coverage mode of the profiles: set, count or atomic
+	funcs    []funcCoverage
+	profiles []*cover.Profile // profiles the coverage was computed from
+	tagSets  []tagSetCoverage // coverage of every build tag set, when several were run
+	failed   []FailedPackage  // packages whose tests failed, left out of the coverage
+}
+
+// tagSetCoverage is the function coverage of the go test run of one build tag set
+type tagSetCoverage struct {
+	tags     string // comma-separated build tags, empty for the default build
+	coverage map[funcPosition]float64
+}
+
+// goTestConfig configures the go test runs collecting coverage
+type goTestConfig struct {
+	args     []string // extra go test arguments, e.g. -short, -race, -timeout=5m
+	env      []string // extra environment variables (KEY=VALUE)
+	tagSets  []string // comma-separated build tags of every run, "" for the default build
+	coverPkg string   // -coverpkg pattern; the tests of every package are then run separately
+}
+
+// runTagSets returns the build tags of every run, a single default build if none were configured
+func (c goTestConfig) runTagSets() []string {
+	if len(c.tagSets) == 0 {
+		return []string{""}
+	}
+	return c.tagSets
+}
+
+// tagSetName returns the report name of a build tag set
+func tagSetName(tags string) string {
+	if tags == "" {
+		return "default"
+	}
+	return tags
+}
+
+// parseTagSets splits the -tags value into build tag sets. "default" (or an
+// empty set) stands for the build without tags.
+func parseTagSets(value string) []string {
+	if strings.TrimSpace(value) == "" {
+		return nil
+	}
+	var sets []string
+	for _, set := range strings.Split(value, ";") {
+		set = strings.TrimSpace(set)
+		if set == "default" {
+			set = ""
+		}
+		sets = append(sets, set)
+	}
+	return sets
+}
+
+// runCoverage runs go test with coverage once per build tag set, unions the
+// profiles and maps them onto the functions in dir. Packages whose tests fail
+// are left out of the coverage and recorded as failed.
+func runCoverage(dir string, cfg goTestConfig, verbose bool) (*coverageData, error) {
+	var runs []*goTestRun
+	var sets [][]*cover.Profile
+	var failed []FailedPackage
+	for _, tags := range cfg.runTagSets() {
+		run, err := runTagSetCoverage(dir, cfg, tags, verbose)
+		if err != nil {
+			if len(cfg.tagSets) > 0 {
+				return nil, fmt.Errorf("tag set %s: %w", tagSetName(tags), err)
+			}
+			return nil, err
+		}
+		runs = append(runs, run)
+		sets = append(sets, run.profiles)
+		failed = mergeFailedPackages(failed, run.failed)
+	}
+
+	mapper := newCoverageMapper(dir)
+	for i := range failed {
+		failed[i].Dir, _ = mapper.resolver.resolve(failed[i].Package)
+	}
+
+	profiles := sets[0]
+	if len(sets) > 1 {
+		var err error
+		profiles, err = maxCoverProfiles(sets...)
+		if err != nil {
+			return nil, err
+		}
+	}
+	data, err := mapper.coverage(profiles)
+	if err != nil {
+		return nil, err
+	}
+	data.failed = failed
+	if len(sets) > 1 {
+		for i, tags := range cfg.tagSets {
+			setData, err := mapper.coverage(sets[i])
+			if err != nil {
+				return nil, err
+			}
+			data.tagSets = append(data.tagSets, tagSetCoverage{tags: tags, coverage: setData.byPosition()})
+		}
+	}
+	if cfg.coverPkg != "" {
+		if err := data.attributeCoverage(mapper, runs); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// runTagSetCoverage runs the tests of one build tag set with coverage: all
+// packages at once, or with -coverpkg the tests of every package separately,
+// so that coverage can be attributed to the packages whose tests produced it
+func runTagSetCoverage(dir string, cfg goTestConfig, tags string, verbose bool) (*goTestRun, error) {
+	if cfg.coverPkg == "" {
+		return runGoTestCoverage(dir, cfg, tags, []string{"./..."}, verbose)
+	}
+
+	pkgs, err := listTestPackages(dir, cfg, tags)
+	if err != nil {
+		return nil, err
+	}
+
+	runs := make([]*goTestRun, len(pkgs))
+	errs := make([]error, len(pkgs))
+	sem := make(chan struct{}, runtime.GOMAXPROCS(0))
+	var wg sync.WaitGroup
+	for i, pkg := range pkgs {
+		wg.Go(func() {
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			runs[i], errs[i] = runGoTestCoverage(dir, cfg, tags, []string{pkg}, verbose)
+		})
+	}
+	wg.Wait()
+
+	merged := &goTestRun{byPackage: make(map[string][]*cover.Profile)}
+	var sets [][]*cover.Profile
+	for i, pkg := range pkgs {
+		if errs[i] != nil {
+			return nil, fmt.Errorf("%s: %w", pkg, errs[i])
+		}
+		merged.failed = mergeFailedPackages(merged.failed, runs[i].failed)
+		// The coverage of a run with failing tests is incomplete
+		if len(runs[i].failed) > 0 {
+			continue
+		}
+		sets = append(sets, runs[i].profiles)
+		merged.byPackage[pkg] = runs[i].profiles
+	}
+	merged.profiles, err = unionCoverProfiles(sets...)
+	if err != nil {
+		return nil, err
+	}
+	return merged, nil
+}
+
+// listTestPackages returns the import paths of the packages under dir that have
+// test files, selected with the same build flags as the go test runs
+func listTestPackages(dir string, cfg goTestConfig, tags string) ([]string, error) {
+	cmdArgs := []string{"list", "-f", "{{if or .TestGoFiles .XTestGoFiles}}{{.ImportPath}}{{end}}"}
+	if tags != "" {
+		cmdArgs = append(cmdArgs, "-tags="+tags)
+	}
+	cmdArgs = append(cmdArgs, packageSelectionArgs(cfg.args)...)
+	cmdArgs = append(cmdArgs, "./...")
+
+	cmd := exec.Command("go", cmdArgs...)
+	cmd.Dir = dir
+	if len(cfg.env) > 0 {
+		cmd.Env = append(os.Environ(), cfg.env...)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("go list failed: %s", stderr.String())
+	}
+	return strings.Fields(string(output)), nil
+}
+
+// packageSelectionFlags are the go build flags that change which packages and
+// files go list sees, mapped to whether they take a separate value
+var packageSelectionFlags = map[string]bool{
+	"mod":     true,
+	"modfile": true,
+	"overlay": true,
+	"tags":    true,
+	"race":    false, // adds the race build tag
+	"msan":    false,
+	"asan":    false,
+}
+
+// packageSelectionArgs returns the extra go test arguments that go list needs
+// to select the same packages, dropping test-only flags such as -short or -run
+func packageSelectionArgs(args []string) []string {
+	var selected []string
+	for i := 0; i < len(args); i++ {
+		if !strings.HasPrefix(args[i], "-") {
+			continue
+		}
+		name, _, hasValue := strings.Cut(strings.TrimLeft(args[i], "-"), "=")
+		takesValue, ok := packageSelectionFlags[name]
+		if !ok {
+			continue
+		}
+		selected = append(selected, args[i])
+		if takesValue && !hasValue && i+1 < len(args) {
+			i++
+			selected = append(selected, args[i])
+		}
+	}
+	return selected
+}
+
+// attributeCoverage records, for every function, the packages whose tests
+// executed it in the separate runs of -coverpkg
+func (c *coverageData) attributeCoverage(mapper *coverageMapper, runs []*goTestRun) error {
+	contributors := make(map[funcPosition]map[string]bool)
+	for _, run := range runs {
+		for pkg, profiles := range run.byPackage {
+			pkgData, err := mapper.coverage(profiles)
+			if err != nil {
+				return err
+			}
+			for _, f := range pkgData.funcs {
+				if f.Coverage == 0 {
+					continue
+				}
+				pos := funcPosition{File: f.File, Line: f.Line}
+				if contributors[pos] == nil {
+					contributors[pos] = make(map[string]bool)
+				}
+				contributors[pos][pkg] = true
+			}
+		}
+	}
+
+	for i, f := range c.funcs {
+		pkgs := slices.Sorted(maps.Keys(contributors[funcPosition{File: f.File, Line: f.Line}]))
+		c.funcs[i].CoveredBy = pkgs
+	}
+	return nil
+}
+
+// goTestRun is the outcome of a go test coverage run
+type goTestRun struct {
+	profiles []*cover.Profile // coverage of the packages whose tests passed
+	failed   []FailedPackage  // packages whose tests failed or did not build, sorted by import path
+
+	byPackage map[string][]*cover.Profile // coverage of the tests of every package (only with -coverpkg)
+}
+
+// testEvent is an event of the go test -json output
+type testEvent struct {
+	Action      string
+	Package     string
+	Test        string
+	FailedBuild string
+}
+
+// runGoTestCoverage runs go test -json with a coverage profile, the configured
+// options, the given build tags and arguments (package patterns, -run filters)
+// and parses the profile. Failing packages do not make the run fail: they are
+// returned along with their failed tests and their blocks are dropped from the
+// profile, since their coverage is incomplete.
+func runGoTestCoverage(dir string, cfg goTestConfig, tags string, args []string, verbose bool) (*goTestRun, error) {
 	// Create temporary file for coverage profile
 	tmpFile, err := os.CreateTemp("", "coverage-*.out")
 	if err != nil {
@@ -25,47 +322,326 @@ func analyzeCoverage(dir string, threshold float64, verbose bool) ([]LowCoverage
 	defer os.Remove(tmpPath)
 
 	// Run go test with coverage
+	cmdArgs := []string{"test", "-json", "-coverprofile=" + tmpPath}
+	if mode := defaultCoverMode(cfg.args); mode != "" {
+		cmdArgs = append(cmdArgs, "-covermode="+mode)
+	}
+	if tags != "" {
+		cmdArgs = append(cmdArgs, "-tags="+tags)
+	}
+	if cfg.coverPkg != "" {
+		cmdArgs = append(cmdArgs, "-coverpkg="+cfg.coverPkg)
+	}
+	cmdArgs = append(cmdArgs, cfg.args...)
+	cmdArgs = append(cmdArgs, args...)
 	if verbose {
-		fmt.Fprintf(os.Stderr, "Running: go test -coverprofile=%s ./...\n", tmpPath)
+		fmt.Fprintf(os.Stderr, "Running: %sgo %s\n", envPrefix(cfg.env), strings.Join(cmdArgs, " "))
 	}
 
-	cmd := exec.Command("go", "test", "-coverprofile="+tmpPath, "./...")
+	cmd := exec.Command("go", cmdArgs...)
 	cmd.Dir = dir
-	var stderr bytes.Buffer
+	if len(cfg.env) > 0 {
+		cmd.Env = append(os.Environ(), cfg.env...)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
 
-	if err := cmd.Run(); err != nil {
+	runErr := cmd.Run()
+	failed := parseTestEvents(stdout.Bytes())
+	if runErr != nil {
 		// Check if tests failed vs other errors
-		if _, ok := err.(*exec.ExitError); ok {
+		if _, ok := runErr.(*exec.ExitError); !ok {
+			return nil, fmt.Errorf("failed to run go test: %w", runErr)
+		}
+		if len(failed) == 0 {
 			return nil, fmt.Errorf("go test failed: %s", stderr.String())
 		}
-		return nil, fmt.Errorf("failed to run go test: %w", err)
 	}
 
-	// Run go tool cover to get function coverage
+	profiles, err := cover.ParseProfiles(tmpPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse coverage profile: %w", err)
+	}
+
+	failedPkgs := make(map[string]bool)
+	for _, f := range failed {
+		failedPkgs[f.Package] = true
+	}
+	var passed []*cover.Profile
+	for _, p := range profiles {
+		if !failedPkgs[path.Dir(p.FileName)] {
+			passed = append(passed, p)
+		}
+	}
+	return &goTestRun{profiles: passed, failed: failed}, nil
+}
+
+// parseTestEvents returns the failed packages and tests of go test -json
+// output, sorted by import path. Lines that are not events are ignored.
+func parseTestEvents(output []byte) []FailedPackage {
+	byPkg := make(map[string]*FailedPackage)
+	for line := range bytes.Lines(output) {
+		var event testEvent
+		if err := json.Unmarshal(line, &event); err != nil || event.Action != "fail" || event.Package == "" {
+			continue
+		}
+		pkg, ok := byPkg[event.Package]
+		if !ok {
+			pkg = &FailedPackage{Package: event.Package}
+			byPkg[event.Package] = pkg
+		}
+		if event.Test != "" {
+			pkg.Tests = append(pkg.Tests, event.Test)
+		}
+		if event.FailedBuild != "" {
+			pkg.BuildFailed = true
+		}
+	}
+
+	var failed []FailedPackage
+	for _, pkg := range byPkg {
+		failed = append(failed, *pkg)
+	}
+	sort.Slice(failed, func(i, j int) bool {
+		return failed[i].Package < failed[j].Package
+	})
+	return failed
+}
+
+// mergeFailedPackages combines the failed packages of several runs, sorted by import path
+func mergeFailedPackages(a, b []FailedPackage) []FailedPackage {
+	byPkg := make(map[string]int)
+	merged := append([]FailedPackage(nil), a...)
+	for i, pkg := range merged {
+		byPkg[pkg.Package] = i
+	}
+	for _, pkg := range b {
+		i, ok := byPkg[pkg.Package]
+		if !ok {
+			byPkg[pkg.Package] = len(merged)
+			merged = append(merged, pkg)
+			continue
+		}
+		for _, test := range pkg.Tests {
+			if !slices.Contains(merged[i].Tests, test) {
+				merged[i].Tests = append(merged[i].Tests, test)
+			}
+		}
+		merged[i].BuildFailed = merged[i].BuildFailed || pkg.BuildFailed
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		return merged[i].Package < merged[j].Package
+	})
+	return merged
+}
+
+// defaultCoverMode returns the coverage mode of the runs: count, so that hit
+// counts are recorded, unless the extra go test arguments choose a mode or
+// enable -race (which requires atomic mode)
+func defaultCoverMode(args []string) string {
+	for _, arg := range args {
+		name, _, _ := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if name == "covermode" || name == "race" {
+			return ""
+		}
+	}
+	return "count"
+}
+
+// envPrefix formats extra environment variables for the verbose command line
+func envPrefix(env []string) string {
+	if len(env) == 0 {
+		return ""
+	}
+	return strings.Join(env, " ") + " "
+}
+
+// loadCoverProfiles merges existing coverage profiles (e.g. one per CI shard)
+// and maps them onto the functions in dir without running any tests
+func loadCoverProfiles(dir string, paths []string, verbose bool) (*coverageData, error) {
 	if verbose {
-		fmt.Fprintf(os.Stderr, "Running: go tool cover -func=%s\n", tmpPath)
+		fmt.Fprintf(os.Stderr, "Loading coverage profiles: %s\n", strings.Join(paths, ", "))
+	}
+	profiles, err := mergeCoverProfiles(paths)
+	if err != nil {
+		return nil, err
 	}
+	return coverageFromProfiles(dir, profiles)
+}
 
-	cmd = exec.Command("go", "tool", "cover", "-func="+tmpPath)
-	cmd.Dir = dir
-	var stdout bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+// mergeCoverProfiles reads and merges coverage profiles. Blocks present in
+// several profiles have their counts summed (count and atomic mode) or or'ed
+// (set mode). Mixing set mode with other modes yields a set mode profile.
+func mergeCoverProfiles(paths []string) ([]*cover.Profile, error) {
+	var modes []string
+	var blocks strings.Builder
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read coverage profile: %w", err)
+		}
 
-	if err := cmd.Run(); err != nil {
-		return nil, fmt.Errorf("failed to run go tool cover: %w\n%s", err, stderr.String())
+		mode := ""
+		for line := range strings.Lines(string(data)) {
+			line = strings.TrimSpace(line)
+			if line == "" {
+				continue
+			}
+			// Concatenated profiles repeat the mode line
+			if m, ok := strings.CutPrefix(line, "mode: "); ok {
+				if mode != "" && m != mode {
+					return nil, fmt.Errorf("%s: inconsistent coverage modes %q and %q", path, mode, m)
+				}
+				mode = m
+				continue
+			}
+			if mode == "" {
+				return nil, fmt.Errorf("%s: missing mode line", path)
+			}
+			blocks.WriteString(line + "\n")
+		}
+		if mode == "" {
+			return nil, fmt.Errorf("%s: empty coverage profile", path)
+		}
+		modes = append(modes, mode)
 	}
 
-	// Parse output and filter by threshold
-	return parseCoverageOutput(stdout.String(), dir, threshold)
+	return parseMergedProfiles(modes, blocks.String())
 }
 
-// getCoverageMap runs go test and returns a map of function names to their coverage percentage
-// This is used to filter out indirectly tested functions from the "missing tests" list
-func getCoverageMap(dir string, verbose bool) (map[string]float64, error) {
-	// Create temporary file for coverage profile
-	tmpFile, err := os.CreateTemp("", "coverage-*.out")
+// unionCoverProfiles merges already parsed profile sets, e.g. unit test and
+// integration coverage, the same way as mergeCoverProfiles
+func unionCoverProfiles(sets ...[]*cover.Profile) ([]*cover.Profile, error) {
+	var modes []string
+	var blocks strings.Builder
+	for _, profiles := range sets {
+		for _, p := range profiles {
+			modes = append(modes, p.Mode)
+			for _, b := range p.Blocks {
+				fmt.Fprintf(&blocks, "%s:%d.%d,%d.%d %d %d\n", p.FileName, b.StartLine, b.StartCol, b.EndLine, b.EndCol, b.NumStmt, b.Count)
+			}
+		}
+	}
+	return parseMergedProfiles(modes, blocks.String())
+}
+
+// maxCoverProfiles merges the profile sets of runs of the same tests, e.g. one
+// per build tag set. Tests built under several tag sets run once per set, so a
+// block gets its highest count of any run instead of the sum.
+func maxCoverProfiles(sets ...[]*cover.Profile) ([]*cover.Profile, error) {
+	type blockKey struct {
+		file                                 string
+		startLine, startCol, endLine, endCol int
+	}
+	var modes []string
+	var keys []blockKey
+	blocks := make(map[blockKey]cover.ProfileBlock)
+	for _, profiles := range sets {
+		for _, p := range profiles {
+			modes = append(modes, p.Mode)
+			for _, b := range p.Blocks {
+				key := blockKey{p.FileName, b.StartLine, b.StartCol, b.EndLine, b.EndCol}
+				seen, ok := blocks[key]
+				if !ok {
+					keys = append(keys, key)
+				}
+				if !ok || b.Count > seen.Count {
+					blocks[key] = b
+				}
+			}
+		}
+	}
+
+	var text strings.Builder
+	for _, key := range keys {
+		b := blocks[key]
+		fmt.Fprintf(&text, "%s:%d.%d,%d.%d %d %d\n", key.file, b.StartLine, b.StartCol, b.EndLine, b.EndCol, b.NumStmt, b.Count)
+	}
+	return parseMergedProfiles(modes, text.String())
+}
+
+// parseMergedProfiles parses profile blocks recorded in the given modes as a
+// single profile, summing or or'ing the counts of repeated blocks
+func parseMergedProfiles(modes []string, blocks string) ([]*cover.Profile, error) {
+	mode := mergedCoverMode(modes)
+	profiles, err := cover.ParseProfilesFromReader(strings.NewReader("mode: " + mode + "\n" + blocks))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse coverage profiles: %w", err)
+	}
+
+	if mode == "set" {
+		for _, p := range profiles {
+			for i := range p.Blocks {
+				p.Blocks[i].Count = min(p.Blocks[i].Count, 1)
+			}
+		}
+	}
+	return profiles, nil
+}
+
+// addIntegrationCoverage converts the GOCOVERDIR directories written by
+// binaries built with go build -cover and unions them with the unit test
+// coverage (nil if unavailable). Functions executed by the integration runs
+// but by no unit test are marked as integration-only.
+func addIntegrationCoverage(dir string, unit *coverageData, covDirs []string, verbose bool) (*coverageData, error) {
+	integration, err := convertCovData(dir, covDirs, verbose)
+	if err != nil {
+		return nil, err
+	}
+
+	unitCovered := make(map[funcPosition]bool)
+	coveredBy := make(map[funcPosition][]string)
+	var unitProfiles []*cover.Profile
+	if unit != nil {
+		unitProfiles = unit.profiles
+		for _, f := range unit.funcs {
+			if f.Coverage > 0 {
+				unitCovered[funcPosition{File: f.File, Line: f.Line}] = true
+			}
+			coveredBy[funcPosition{File: f.File, Line: f.Line}] = f.CoveredBy
+		}
+	}
+
+	profiles, err := unionCoverProfiles(unitProfiles, integration)
+	if err != nil {
+		return nil, err
+	}
+	merged, err := coverageFromProfiles(dir, profiles)
+	if err != nil {
+		return nil, err
+	}
+	if unit == nil {
+		// Without unit coverage, nothing can be labelled integration-only
+		fmt.Fprintf(os.Stderr, "Warning: unit test coverage is unavailable, using integration coverage without integration-only labels\n")
+		return merged, nil
+	}
+	for i, f := range merged.funcs {
+		pos := funcPosition{File: f.File, Line: f.Line}
+		if f.Coverage > 0 && !unitCovered[pos] {
+			merged.funcs[i].IntegrationOnly = true
+		}
+		merged.funcs[i].CoveredBy = coveredBy[pos]
+	}
+	merged.tagSets = unit.tagSets
+	merged.failed = unit.failed
+	return merged, nil
+}
+
+// convertCovData converts binary coverage data directories to a text profile
+// with go tool covdata and parses it
+func convertCovData(dir string, covDirs []string, verbose bool) ([]*cover.Profile, error) {
+	// go tool covdata runs in dir, so relative paths must be resolved first
+	absDirs := make([]string, len(covDirs))
+	for i, d := range covDirs {
+		abs, err := filepath.Abs(d)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve coverage directory: %w", err)
+		}
+		absDirs[i] = abs
+	}
+
+	tmpFile, err := os.CreateTemp("", "covdata-*.out")
 	if err != nil {
 		return nil, fmt.Errorf("failed to create temp file: %w", err)
 	}
@@ -73,133 +649,445 @@ func getCoverageMap(dir string, verbose bool) (map[string]float64, error) {
 	tmpFile.Close()
 	defer os.Remove(tmpPath)
 
-	// Run go test with coverage
+	cmdArgs := []string{"tool", "covdata", "textfmt", "-i=" + strings.Join(absDirs, ","), "-o=" + tmpPath}
 	if verbose {
-		fmt.Fprintf(os.Stderr, "Running: go test -coverprofile=%s ./...\n", tmpPath)
+		fmt.Fprintf(os.Stderr, "Running: go %s\n", strings.Join(cmdArgs, " "))
 	}
 
-	cmd := exec.Command("go", "test", "-coverprofile="+tmpPath, "./...")
+	cmd := exec.Command("go", cmdArgs...)
 	cmd.Dir = dir
 	var stderr bytes.Buffer
 	cmd.Stderr = &stderr
 
 	if err := cmd.Run(); err != nil {
 		if _, ok := err.(*exec.ExitError); ok {
-			return nil, fmt.Errorf("go test failed: %s", stderr.String())
+			return nil, fmt.Errorf("go tool covdata failed: %s", stderr.String())
 		}
-		return nil, fmt.Errorf("failed to run go test: %w", err)
+		return nil, fmt.Errorf("failed to run go tool covdata: %w", err)
 	}
 
-	// Run go tool cover to get function coverage
-	if verbose {
-		fmt.Fprintf(os.Stderr, "Running: go tool cover -func=%s\n", tmpPath)
+	profiles, err := cover.ParseProfiles(tmpPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse integration coverage profile: %w", err)
 	}
+	return profiles, nil
+}
 
-	cmd = exec.Command("go", "tool", "cover", "-func="+tmpPath)
-	cmd.Dir = dir
-	var stdout bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-
-	if err := cmd.Run(); err != nil {
-		return nil, fmt.Errorf("failed to run go tool cover: %w\n%s", err, stderr.String())
+// mergedCoverMode returns the mode of merged profiles: the common mode, set if
+// any profile only records whether blocks ran, or count otherwise
+func mergedCoverMode(modes []string) string {
+	if len(modes) == 0 {
+		return "set"
+	}
+	merged := modes[0]
+	for _, mode := range modes[1:] {
+		switch {
+		case mode == merged:
+		case mode == "set" || merged == "set":
+			merged = "set"
+		default:
+			merged = "count"
+		}
 	}
+	return merged
+}
 
-	return parseCoverageToMap(stdout.String(), dir)
+// coverageFromProfiles maps the blocks of coverage profiles onto the functions
+// declared in the profiled files under dir. Files outside dir are ignored.
+func coverageFromProfiles(dir string, profiles []*cover.Profile) (*coverageData, error) {
+	return newCoverageMapper(dir).coverage(profiles)
 }
 
-// parseCoverageToMap parses go tool cover output into a map of function name -> coverage %
-func parseCoverageToMap(output, baseDir string) (map[string]float64, error) {
-	result := make(map[string]float64)
+// coverageMapper maps several sets of coverage profiles of the same files onto
+// their functions, parsing every file only once
+type coverageMapper struct {
+	dir      string
+	resolver *profilePathResolver
+	extents  map[string][]funcExtent // by path relative to dir
+}
 
-	re := regexp.MustCompile(`^(.+):(\d+):\s+(\S+)\s+(\d+\.?\d*)%$`)
+// newCoverageMapper returns a mapper for the profiled files under dir
+func newCoverageMapper(dir string) *coverageMapper {
+	return &coverageMapper{
+		dir:      dir,
+		resolver: newProfilePathResolver(dir),
+		extents:  make(map[string][]funcExtent),
+	}
+}
 
-	scanner := bufio.NewScanner(strings.NewReader(output))
-	for scanner.Scan() {
-		line := scanner.Text()
+// funcExtents returns the function extents of a file, parsing it on first use
+func (m *coverageMapper) funcExtents(relPath string) ([]funcExtent, error) {
+	if extents, ok := m.extents[relPath]; ok {
+		return extents, nil
+	}
+	extents, err := parseFuncExtents(filepath.Join(m.dir, relPath))
+	if err != nil {
+		return nil, err
+	}
+	m.extents[relPath] = extents
+	return extents, nil
+}
 
-		if strings.HasPrefix(line, "total:") {
+// coverage maps the blocks of coverage profiles onto the functions of the profiled files
+func (m *coverageMapper) coverage(profiles []*cover.Profile) (*coverageData, error) {
+	var funcs []funcCoverage
+	mode := ""
+	for _, p := range profiles {
+		mode = p.Mode
+		relPath, ok := m.resolver.resolve(p.FileName)
+		if !ok {
 			continue
 		}
+		extents, err := m.funcExtents(relPath)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range extents {
+			coverage, uncovered := e.coverage(p.Blocks)
+			f := funcCoverage{
+				File:      relPath,
+				Line:      e.startLine,
+				Name:      e.name,
+				Receiver:  e.receiver,
+				Coverage:  coverage,
+				Uncovered: uncovered,
+				Package:   path.Dir(p.FileName),
+			}
+			if p.Mode != "set" {
+				f.MinHits, f.MaxHits = e.hits(p.Blocks)
+			}
+			funcs = append(funcs, f)
+		}
+	}
 
-		matches := re.FindStringSubmatch(line)
-		if matches == nil {
-			continue
+	sort.Slice(funcs, func(i, j int) bool {
+		if funcs[i].File != funcs[j].File {
+			return funcs[i].File < funcs[j].File
 		}
+		return funcs[i].Line < funcs[j].Line
+	})
+
+	return &coverageData{mode: mode, funcs: funcs, profiles: profiles}, nil
+}
 
-		funcName := matches[3]
-		coverage, _ := strconv.ParseFloat(matches[4], 64)
+// profilePathResolver maps the import path based file names of coverage
+// profiles (example.com/mod/pkg/file.go) to paths relative to the analyzed directory
+type profilePathResolver struct {
+	dir     string
+	modRoot string // directory containing go.mod, empty if not in a module
+	modPath string // module path declared in go.mod
+}
 
-		// Store the function name -> coverage mapping
-		result[funcName] = coverage
+// newProfilePathResolver finds the module containing dir
+func newProfilePathResolver(dir string) *profilePathResolver {
+	r := &profilePathResolver{dir: dir}
+	for d := dir; ; d = filepath.Dir(d) {
+		if data, err := os.ReadFile(filepath.Join(d, "go.mod")); err == nil {
+			r.modRoot = d
+			r.modPath = modfile.ModulePath(data)
+			break
+		}
+		if filepath.Dir(d) == d {
+			break
+		}
 	}
+	return r
+}
 
-	return result, nil
+// resolve returns the path of a profile file name (or of a package import
+// path) relative to the analyzed directory, or false if it is outside of it
+func (r *profilePathResolver) resolve(name string) (string, bool) {
+	var abs string
+	switch {
+	case filepath.IsAbs(name):
+		abs = name
+	case r.modPath != "" && name == r.modPath:
+		abs = r.modRoot
+	case r.modPath != "" && strings.HasPrefix(name, r.modPath+"/"):
+		abs = filepath.Join(r.modRoot, filepath.FromSlash(strings.TrimPrefix(name, r.modPath+"/")))
+	default:
+		return "", false
+	}
+
+	rel, err := filepath.Rel(r.dir, abs)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return "", false
+	}
+	return rel, true
 }
 
-// parseCoverageOutput parses go tool cover -func output
-// Format: file:line:	funcName		percentage%
-func parseCoverageOutput(output, baseDir string, threshold float64) ([]LowCoverageFunc, error) {
-	var result []LowCoverageFunc
+// funcExtent is the source range of a function declaration or of a
+// package-level function literal unit
+type funcExtent struct {
+	name      string
+	receiver  string
+	startLine int
+	startCol  int
+	endLine   int
+	endCol    int
+}
+
+// parseFuncExtents returns the extents of the functions declared in a file
+func parseFuncExtents(path string) ([]funcExtent, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, path, nil, parser.SkipObjectResolution)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	var extents []funcExtent
+	add := func(name, receiver string, node ast.Node) {
+		start, end := fset.Position(node.Pos()), fset.Position(node.End())
+		extents = append(extents, funcExtent{
+			name:      name,
+			receiver:  receiver,
+			startLine: start.Line,
+			startCol:  start.Column,
+			endLine:   end.Line,
+			endCol:    end.Column,
+		})
+	}
+
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			var receiver string
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				receiver = getReceiverType(d.Recv.List[0].Type)
+			}
+			add(d.Name.Name, receiver, d)
+		case *ast.GenDecl:
+			for _, unit := range extractFuncLitUnits(d) {
+				add(unit.name, "", unit.lit)
+			}
+		}
+	}
+	return extents, nil
+}
 
-	// Regex to match coverage lines
-	// Example: github.com/user/pkg/file.go:20:	funcName		85.7%
-	re := regexp.MustCompile(`^(.+):(\d+):\s+(\S+)\s+(\d+\.?\d*)%$`)
+// coverage computes the statement coverage of the function from the blocks
+// of its file (sorted by start position), along with the line ranges of its
+// statements that were never executed
+func (e funcExtent) coverage(blocks []cover.ProfileBlock) (float64, []LineRange) {
+	var total, covered int
+	var uncovered []LineRange
+	for _, b := range e.blocksWithin(blocks) {
+		total += b.NumStmt
+		if b.Count > 0 {
+			covered += b.NumStmt
+			continue
+		}
+		if b.NumStmt == 0 {
+			continue
+		}
+		if n := len(uncovered); n > 0 && b.StartLine <= uncovered[n-1].End+1 {
+			uncovered[n-1].End = max(uncovered[n-1].End, b.EndLine)
+		} else {
+			uncovered = append(uncovered, LineRange{Start: b.StartLine, End: b.EndLine})
+		}
+	}
 
-	scanner := bufio.NewScanner(strings.NewReader(output))
-	for scanner.Scan() {
-		line := scanner.Text()
+	if total == 0 {
+		return 0, nil
+	}
+	return 100 * float64(covered) / float64(total), uncovered
+}
 
-		// Skip total line
-		if strings.HasPrefix(line, "total:") {
+// hits returns the lowest and highest execution counts of the function's
+// statement blocks
+func (e funcExtent) hits(blocks []cover.ProfileBlock) (int, int) {
+	minHits, maxHits := -1, 0
+	for _, b := range e.blocksWithin(blocks) {
+		if b.NumStmt == 0 {
 			continue
 		}
+		if minHits < 0 || b.Count < minHits {
+			minHits = b.Count
+		}
+		maxHits = max(maxHits, b.Count)
+	}
+	return max(minHits, 0), maxHits
+}
 
-		matches := re.FindStringSubmatch(line)
-		if matches == nil {
+// blocksWithin returns the blocks of the function's file (sorted by start
+// position) that lie within the function
+func (e funcExtent) blocksWithin(blocks []cover.ProfileBlock) []cover.ProfileBlock {
+	var within []cover.ProfileBlock
+	for _, b := range blocks {
+		// Blocks starting at or after the end of the function
+		if b.StartLine > e.endLine || (b.StartLine == e.endLine && b.StartCol >= e.endCol) {
+			break
+		}
+		// Blocks ending before the start of the function
+		if b.EndLine < e.startLine || (b.EndLine == e.startLine && b.EndCol <= e.startCol) {
 			continue
 		}
+		within = append(within, b)
+	}
+	return within
+}
 
-		filePath := matches[1]
-		lineNum, _ := strconv.Atoi(matches[2])
-		funcName := matches[3]
-		coverage, _ := strconv.ParseFloat(matches[4], 64)
+// funcPosition identifies a function by its file (relative to the analyzed
+// directory) and declaration line, which is unique even when names repeat
+// across types and packages
+type funcPosition struct {
+	File string
+	Line int
+}
 
-		// Skip if above threshold
-		if coverage >= threshold {
+// byPosition returns a map of function positions to their coverage percentage.
+// This is used to filter out indirectly tested functions from the "missing tests" list
+func (c *coverageData) byPosition() map[funcPosition]float64 {
+	result := make(map[funcPosition]float64)
+	for _, f := range c.funcs {
+		result[funcPosition{File: f.File, Line: f.Line}] = f.Coverage
+	}
+	return result
+}
+
+// unknownDirs returns the directories of the packages whose tests failed,
+// relative to the analyzed directory
+func (c *coverageData) unknownDirs() map[string]bool {
+	dirs := make(map[string]bool)
+	for _, pkg := range c.failed {
+		if pkg.Dir != "" {
+			dirs[pkg.Dir] = true
+		}
+	}
+	return dirs
+}
+
+// integrationOnly returns the positions of the functions executed only by
+// integration runs
+func (c *coverageData) integrationOnly() map[funcPosition]bool {
+	result := make(map[funcPosition]bool)
+	for _, f := range c.funcs {
+		if f.IntegrationOnly {
+			result[funcPosition{File: f.File, Line: f.Line}] = true
+		}
+	}
+	return result
+}
+
+// compareTagSets returns the build tag sets that were run and the functions
+// whose coverage differs between them, sorted by file and line
+func (c *coverageData) compareTagSets() ([]string, []TagSetCoverage) {
+	if len(c.tagSets) < 2 {
+		return nil, nil
+	}
+
+	names := make([]string, len(c.tagSets))
+	for i, set := range c.tagSets {
+		names[i] = tagSetName(set.tags)
+	}
+
+	var diffs []TagSetCoverage
+	for _, f := range c.funcs {
+		pos := funcPosition{File: f.File, Line: f.Line}
+		coverage := make([]float64, len(c.tagSets))
+		differs := false
+		for i, set := range c.tagSets {
+			// Functions in files excluded by a tag set count as not covered
+			coverage[i] = set.coverage[pos]
+			if coverage[i] != coverage[0] {
+				differs = true
+			}
+		}
+		if differs {
+			diffs = append(diffs, TagSetCoverage{
+				File:     f.File,
+				Line:     f.Line,
+				Name:     f.Name,
+				Receiver: f.Receiver,
+				Coverage: coverage,
+			})
+		}
+	}
+	return names, diffs
+}
+
+// crossPackage returns the functions executed by the tests of other packages
+// than their own, with all packages whose tests executed them
+func (c *coverageData) crossPackage() []CrossPackageFunc {
+	var result []CrossPackageFunc
+	for _, f := range c.funcs {
+		if !slices.ContainsFunc(f.CoveredBy, func(pkg string) bool { return pkg != f.Package }) {
 			continue
 		}
+		result = append(result, CrossPackageFunc{
+			File:     f.File,
+			Line:     f.Line,
+			Name:     f.Name,
+			Receiver: f.Receiver,
+			Coverage: f.Coverage,
+			Packages: f.CoveredBy,
+		})
+	}
+	return result
+}
 
-		// Skip main and init functions (typically not unit tested)
-		if funcName == "main" || funcName == "init" {
+// hasHitCounts reports whether the profiles record how often blocks ran
+func (c *coverageData) hasHitCounts() bool {
+	return c.mode == "count" || c.mode == "atomic"
+}
+
+// barelyExercised returns the covered functions none of whose blocks ran more
+// than maxHits times, ranked by their highest hit count, then by file and line
+func (c *coverageData) barelyExercised(maxHits int) []BarelyExercisedFunc {
+	var result []BarelyExercisedFunc
+	for _, f := range c.funcs {
+		if f.Coverage == 0 || f.MaxHits > maxHits {
 			continue
 		}
+		result = append(result, BarelyExercisedFunc{
+			File:     f.File,
+			Line:     f.Line,
+			Name:     f.Name,
+			Receiver: f.Receiver,
+			Coverage: f.Coverage,
+			MinHits:  f.MinHits,
+			MaxHits:  f.MaxHits,
+			Limit:    maxHits,
+		})
+	}
 
-		// Convert absolute path to relative
-		relPath := filePath
-		if abs, err := filepath.Abs(baseDir); err == nil {
-			if rel, err := filepath.Rel(abs, filePath); err == nil && !strings.HasPrefix(rel, "..") {
-				relPath = rel
-			}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].MaxHits < result[j].MaxHits
+	})
+	return result
+}
+
+// lowCoverage returns the functions with coverage below the threshold
+func (c *coverageData) lowCoverage(threshold float64) []LowCoverageFunc {
+	return filterLowCoverage(c.funcs, threshold)
+}
+
+// filterLowCoverage returns the functions with coverage below the threshold,
+// sorted by file and line
+func filterLowCoverage(funcs []funcCoverage, threshold float64) []LowCoverageFunc {
+	var result []LowCoverageFunc
+
+	for _, f := range funcs {
+		// Skip if above threshold
+		if f.Coverage >= threshold {
+			continue
 		}
 
-		// Try to extract just the file path from module path
-		// e.g., github.com/user/pkg/file.go -> file.go (if in same dir)
-		parts := strings.Split(filePath, "/")
-		if len(parts) > 0 {
-			fileName := parts[len(parts)-1]
-			// Check if file exists in the directory
-			if _, err := os.Stat(filepath.Join(baseDir, fileName)); err == nil {
-				relPath = fileName
-			}
+		// Skip main and init functions (typically not unit tested)
+		if f.Name == "main" || f.Name == "init" {
+			continue
 		}
 
 		result = append(result, LowCoverageFunc{
-			File:      relPath,
-			Line:      lineNum,
-			Name:      funcName,
-			Coverage:  coverage,
+			File:      f.File,
+			Line:      f.Line,
+			Name:      f.Name,
+			Receiver:  f.Receiver,
+			Coverage:  f.Coverage,
 			Threshold: threshold,
+			MinHits:   f.MinHits,
+			MaxHits:   f.MaxHits,
+			Uncovered: f.Uncovered,
 		})
 	}
 
@@ -211,5 +1099,32 @@ func parseCoverageOutput(output, baseDir string, threshold float64) ([]LowCovera
 		return result[i].Line < result[j].Line
 	})
 
-	return result, nil
+	return result
+}
+
+// addUncoveredSource reads the uncovered lines of low coverage functions from
+// their files under dir
+func addUncoveredSource(dir string, funcs []LowCoverageFunc) error {
+	fileLines := make(map[string][]string)
+	for i, f := range funcs {
+		if len(f.Uncovered) == 0 {
+			continue
+		}
+		lines, ok := fileLines[f.File]
+		if !ok {
+			data, err := os.ReadFile(filepath.Join(dir, f.File))
+			if err != nil {
+				return fmt.Errorf("failed to read source: %w", err)
+			}
+			lines = strings.Split(string(data), "\n")
+			fileLines[f.File] = lines
+		}
+
+		for _, r := range f.Uncovered {
+			for line := r.Start; line <= r.End && line <= len(lines); line++ {
+				funcs[i].UncoveredSource = append(funcs[i].UncoveredSource, SourceLine{Line: line, Text: lines[line-1]})
+			}
+		}
+	}
+	return nil
 }
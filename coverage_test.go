@@ -1,34 +1,39 @@
 package main
 
 import (
+	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
 	"testing"
+
+	"golang.org/x/tools/cover"
 )
 
-func TestParseCoverageOutput(t *testing.T) {
-	output := `github.com/example/pkg/file.go:20:	FuncA		85.7%
-github.com/example/pkg/file.go:35:	FuncB		50.0%
-github.com/example/pkg/other.go:10:	FuncC		100.0%
-total:					(statements)	78.5%`
+func TestFilterLowCoverage(t *testing.T) {
+	funcs := []funcCoverage{
+		{File: "file.go", Line: 20, Name: "FuncA", Coverage: 85.7},
+		{File: "file.go", Line: 35, Name: "FuncB", Coverage: 50.0},
+		{File: "other.go", Line: 10, Name: "FuncC", Coverage: 100.0},
+	}
 
 	tests := []struct {
 		name          string
 		threshold     float64
 		expectedCount int
 	}{
-		{"threshold 90", 90, 2},  // FuncA (85.7%) and FuncB (50.0%)
-		{"threshold 60", 60, 1},  // Only FuncB (50.0%)
-		{"threshold 50", 50, 0},  // None below 50
+		{"threshold 90", 90, 2},   // FuncA (85.7%) and FuncB (50.0%)
+		{"threshold 60", 60, 1},   // Only FuncB (50.0%)
+		{"threshold 50", 50, 0},   // None below 50
 		{"threshold 100", 100, 2}, // FuncA and FuncB (FuncC is exactly 100)
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result, err := parseCoverageOutput(output, "/tmp", tt.threshold)
-			if err != nil {
-				t.Fatalf("parseCoverageOutput failed: %v", err)
-			}
+			result := filterLowCoverage(funcs, tt.threshold)
 			if len(result) != tt.expectedCount {
 				t.Errorf("Expected %d functions below threshold %.1f, got %d",
 					tt.expectedCount, tt.threshold, len(result))
@@ -40,25 +45,23 @@ total:					(statements)	78.5%`
 	}
 }
 
-func TestParseCoverageOutput_Fields(t *testing.T) {
-	output := `github.com/example/pkg/file.go:25:	MyFunc		75.5%
-total:					(statements)	75.5%`
-
-	result, err := parseCoverageOutput(output, "/tmp", 80)
-	if err != nil {
-		t.Fatalf("parseCoverageOutput failed: %v", err)
+func TestFilterLowCoverage_Fields(t *testing.T) {
+	funcs := []funcCoverage{
+		{File: "file.go", Line: 25, Name: "MyFunc", Receiver: "Store", Coverage: 75.5},
+		{File: "file.go", Line: 40, Name: "init", Coverage: 0},
 	}
 
+	result := filterLowCoverage(funcs, 80)
 	if len(result) != 1 {
 		t.Fatalf("Expected 1 result, got %d", len(result))
 	}
 
 	f := result[0]
-	if f.Name != "MyFunc" {
-		t.Errorf("Expected Name 'MyFunc', got %q", f.Name)
+	if f.Name != "MyFunc" || f.Receiver != "Store" {
+		t.Errorf("Expected (Store).MyFunc, got (%s).%s", f.Receiver, f.Name)
 	}
-	if f.Line != 25 {
-		t.Errorf("Expected Line 25, got %d", f.Line)
+	if f.File != "file.go" || f.Line != 25 {
+		t.Errorf("Expected file.go:25, got %s:%d", f.File, f.Line)
 	}
 	if f.Coverage != 75.5 {
 		t.Errorf("Expected Coverage 75.5, got %.1f", f.Coverage)
@@ -68,17 +71,14 @@ total:					(statements)	75.5%`
 	}
 }
 
-func TestParseCoverageOutput_Sorting(t *testing.T) {
-	output := `github.com/pkg/b.go:20:	FuncB		50.0%
-github.com/pkg/a.go:30:	FuncA2		40.0%
-github.com/pkg/a.go:10:	FuncA1		30.0%
-total:					(statements)	40.0%`
-
-	result, err := parseCoverageOutput(output, "/tmp", 100)
-	if err != nil {
-		t.Fatalf("parseCoverageOutput failed: %v", err)
+func TestFilterLowCoverage_Sorting(t *testing.T) {
+	funcs := []funcCoverage{
+		{File: "b.go", Line: 20, Name: "FuncB", Coverage: 50},
+		{File: "a.go", Line: 30, Name: "FuncA2", Coverage: 40},
+		{File: "a.go", Line: 10, Name: "FuncA1", Coverage: 30},
 	}
 
+	result := filterLowCoverage(funcs, 100)
 	if len(result) != 3 {
 		t.Fatalf("Expected 3 results, got %d", len(result))
 	}
@@ -93,7 +93,7 @@ total:					(statements)	40.0%`
 	}
 }
 
-func TestAnalyzeCoverage_Integration(t *testing.T) {
+func TestRunCoverage_Integration(t *testing.T) {
 	// Create a temporary Go project
 	tmpDir, err := os.MkdirTemp("", "test-coverage-*")
 	if err != nil {
@@ -156,10 +156,11 @@ func TestPartiallyTested(t *testing.T) {
 	}
 
 	// Run coverage analysis with threshold 80
-	result, err := analyzeCoverage(tmpDir, 80, false)
+	coverage, err := runCoverage(tmpDir, goTestConfig{}, false)
 	if err != nil {
-		t.Fatalf("analyzeCoverage failed: %v", err)
+		t.Fatalf("runCoverage failed: %v", err)
 	}
+	result := coverage.lowCoverage(80)
 
 	// Should find PartiallyTested (66.7%) and UntestedFunc (0%)
 	if len(result) < 1 {
@@ -179,80 +180,956 @@ func TestPartiallyTested(t *testing.T) {
 	if !foundUntested {
 		t.Error("Expected to find UntestedFunc in low coverage results")
 	}
+
+	// The same run also feeds the "missing tests" filter
+	if cov := coverage.byPosition()[funcPosition{File: "source.go", Line: 3}]; cov != 100 {
+		t.Errorf("Expected TestedFunc coverage 100%%, got %.1f%%", cov)
+	}
+
+	// Hit counts are recorded by default
+	if !coverage.hasHitCounts() {
+		t.Errorf("Expected hit counts, got mode %q", coverage.mode)
+	}
 }
 
-func TestAnalyzeCoverage_TestsFail(t *testing.T) {
-	// Create a project where tests fail
-	tmpDir, err := os.MkdirTemp("", "test-failing-*")
-	if err != nil {
-		t.Fatalf("Failed to create temp dir: %v", err)
+// coverageSource is a module file whose coverage blocks are listed in coverageProfile
+const coverageSource = `package testpkg
+
+type Store struct{}
+
+func (s *Store) Close() error {
+	if s == nil {
+		return nil
 	}
-	defer os.RemoveAll(tmpDir)
+	return nil
+}
 
-	// Create go.mod
-	goMod := `module testpkg
+func Empty() {}
 
-go 1.21
+func OneLine() int { return 1 }
+
+var parseHeader = func(h string) string {
+	if h == "" {
+		return "default"
+	}
+	return h
+}
 `
-	err = os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte(goMod), 0644)
+
+const coverageProfile = `mode: set
+testpkg/store.go:5.30,6.13 1 1
+testpkg/store.go:6.13,8.3 1 0
+testpkg/store.go:9.2,9.12 1 1
+testpkg/store.go:14.20,14.30 1 1
+testpkg/store.go:16.42,17.13 1 1
+testpkg/store.go:17.13,19.3 1 0
+testpkg/store.go:20.2,20.10 1 0
+example.com/other/file.go:3.10,5.2 1 1
+`
+
+func TestCoverageFromProfiles(t *testing.T) {
+	tmpDir := writeProjectFiles(t, map[string]string{
+		"go.mod":   "module testpkg\n\ngo 1.21\n",
+		"store.go": coverageSource,
+	})
+
+	profiles, err := cover.ParseProfilesFromReader(strings.NewReader(coverageProfile))
 	if err != nil {
-		t.Fatalf("Failed to write go.mod: %v", err)
+		t.Fatalf("Failed to parse profile: %v", err)
+	}
+	coverage, err := coverageFromProfiles(tmpDir, profiles)
+	if err != nil {
+		t.Fatalf("coverageFromProfiles failed: %v", err)
 	}
 
-	// Create source file
-	sourceContent := `package testpkg
+	want := []funcCoverage{
+		{File: "store.go", Line: 5, Name: "Close", Receiver: "Store", Coverage: 200.0 / 3, Uncovered: []LineRange{{6, 8}}, Package: "testpkg"},
+		{File: "store.go", Line: 12, Name: "Empty", Coverage: 0, Package: "testpkg"},
+		{File: "store.go", Line: 14, Name: "OneLine", Coverage: 100, Package: "testpkg"},
+		{File: "store.go", Line: 16, Name: "parseHeader", Coverage: 100.0 / 3, Uncovered: []LineRange{{17, 20}}, Package: "testpkg"},
+	}
+	if !reflect.DeepEqual(coverage.funcs, want) {
+		t.Errorf("coverageFromProfiles() = %+v, want %+v", coverage.funcs, want)
+	}
+
+	low := coverage.lowCoverage(50)
+	if len(low) != 2 || low[0].Name != "Empty" || low[1].Name != "parseHeader" {
+		t.Errorf("Expected Empty and parseHeader below 50%%, got %v", low)
+	}
+	if byPosition := coverage.byPosition(); byPosition[funcPosition{File: "store.go", Line: 14}] != 100 {
+		t.Errorf("Expected OneLine coverage 100%%, got %v", byPosition)
+	}
+}
+
+func TestCoverageMapper_ParsesFilesOnce(t *testing.T) {
+	tmpDir := writeProjectFiles(t, map[string]string{
+		"go.mod":   "module testpkg\n\ngo 1.21\n",
+		"store.go": coverageSource,
+	})
+
+	profiles, err := cover.ParseProfilesFromReader(strings.NewReader(coverageProfile))
+	if err != nil {
+		t.Fatalf("Failed to parse profile: %v", err)
+	}
+	mapper := newCoverageMapper(tmpDir)
+	first, err := mapper.coverage(profiles)
+	if err != nil {
+		t.Fatalf("coverage failed: %v", err)
+	}
+
+	// Later profile sets reuse the extents parsed for the first one
+	if err := os.Remove(filepath.Join(tmpDir, "store.go")); err != nil {
+		t.Fatal(err)
+	}
+	second, err := mapper.coverage(profiles)
+	if err != nil {
+		t.Fatalf("coverage of a second profile set failed: %v", err)
+	}
+	if !reflect.DeepEqual(first.funcs, second.funcs) {
+		t.Errorf("coverage() = %+v, want %+v", second.funcs, first.funcs)
+	}
+}
+
+func TestAddUncoveredSource(t *testing.T) {
+	tmpDir := writeProjectFiles(t, map[string]string{
+		"go.mod":   "module testpkg\n\ngo 1.21\n",
+		"store.go": coverageSource,
+	})
+
+	profiles, err := cover.ParseProfilesFromReader(strings.NewReader(coverageProfile))
+	if err != nil {
+		t.Fatalf("Failed to parse profile: %v", err)
+	}
+	coverage, err := coverageFromProfiles(tmpDir, profiles)
+	if err != nil {
+		t.Fatalf("coverageFromProfiles failed: %v", err)
+	}
+
+	low := coverage.lowCoverage(80)
+	if err := addUncoveredSource(tmpDir, low); err != nil {
+		t.Fatalf("addUncoveredSource failed: %v", err)
+	}
 
-func Foo() int { return 42 }
+	uncovered := make(map[string][]LineRange)
+	source := make(map[string][]SourceLine)
+	for _, f := range low {
+		uncovered[f.Name] = f.Uncovered
+		source[f.Name] = f.UncoveredSource
+	}
+	wantUncovered := map[string][]LineRange{"Close": {{6, 8}}, "Empty": nil, "parseHeader": {{17, 20}}}
+	if !reflect.DeepEqual(uncovered, wantUncovered) {
+		t.Errorf("Uncovered = %v, want %v", uncovered, wantUncovered)
+	}
+	wantSource := map[string][]SourceLine{
+		"Close": {{6, "\tif s == nil {"}, {7, "\t\treturn nil"}, {8, "\t}"}},
+		"Empty": nil,
+		"parseHeader": {
+			{17, "\tif h == \"\" {"},
+			{18, "\t\treturn \"default\""},
+			{19, "\t}"},
+			{20, "\treturn h"},
+		},
+	}
+	if !reflect.DeepEqual(source, wantSource) {
+		t.Errorf("UncoveredSource = %q, want %q", source, wantSource)
+	}
+}
+
+func TestCoverageFromProfiles_HitCounts(t *testing.T) {
+	tmpDir := writeProjectFiles(t, map[string]string{
+		"go.mod":   "module testpkg\n\ngo 1.21\n",
+		"store.go": coverageSource,
+	})
+
+	profile := `mode: count
+testpkg/store.go:5.30,6.13 1 7
+testpkg/store.go:6.13,8.3 1 0
+testpkg/store.go:9.2,9.12 1 7
+testpkg/store.go:14.20,14.30 1 1
+testpkg/store.go:16.42,17.13 1 3
+testpkg/store.go:17.13,19.3 1 2
+testpkg/store.go:20.2,20.10 1 1
 `
-	err = os.WriteFile(filepath.Join(tmpDir, "source.go"), []byte(sourceContent), 0644)
+	profiles, err := cover.ParseProfilesFromReader(strings.NewReader(profile))
 	if err != nil {
-		t.Fatalf("Failed to write source file: %v", err)
+		t.Fatalf("Failed to parse profile: %v", err)
+	}
+	coverage, err := coverageFromProfiles(tmpDir, profiles)
+	if err != nil {
+		t.Fatalf("coverageFromProfiles failed: %v", err)
 	}
 
-	// Create a failing test
-	testContent := `package testpkg
+	hits := make(map[string][2]int)
+	for _, f := range coverage.funcs {
+		hits[f.Name] = [2]int{f.MinHits, f.MaxHits}
+	}
+	wantHits := map[string][2]int{"Close": {0, 7}, "Empty": {0, 0}, "OneLine": {1, 1}, "parseHeader": {1, 3}}
+	if !reflect.DeepEqual(hits, wantHits) {
+		t.Errorf("Hits = %v, want %v", hits, wantHits)
+	}
+
+	var barely []string
+	for _, f := range coverage.barelyExercised(3) {
+		barely = append(barely, f.Name)
+	}
+	if want := []string{"OneLine", "parseHeader"}; !reflect.DeepEqual(barely, want) {
+		t.Errorf("barelyExercised(3) = %v, want %v", barely, want)
+	}
+
+	low := coverage.lowCoverage(80)
+	if len(low) != 2 || low[0].Name != "Close" || low[0].MinHits != 0 || low[0].MaxHits != 7 {
+		t.Errorf("Expected Close with hits 0-7 first below 80%%, got %v", low)
+	}
+}
+
+func TestDefaultCoverMode(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{nil, "count"},
+		{[]string{"-short", "-timeout=5m"}, "count"},
+		{[]string{"-race"}, ""},
+		{[]string{"-covermode=atomic"}, ""},
+		{[]string{"--covermode", "set"}, ""},
+	}
+
+	for _, tt := range tests {
+		if got := defaultCoverMode(tt.args); got != tt.want {
+			t.Errorf("defaultCoverMode(%q) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestPackageSelectionArgs(t *testing.T) {
+	tests := []struct {
+		args []string
+		want []string
+	}{
+		{nil, nil},
+		{[]string{"-short", "-run", "TestFoo", "-timeout=5m"}, nil},
+		{[]string{"-race", "-short"}, []string{"-race"}},
+		{[]string{"-mod=vendor", "-count=1"}, []string{"-mod=vendor"}},
+		{[]string{"--modfile", "go.test.mod", "-v"}, []string{"--modfile", "go.test.mod"}},
+		{[]string{"-tags", "integration"}, []string{"-tags", "integration"}},
+	}
+
+	for _, tt := range tests {
+		if got := packageSelectionArgs(tt.args); !slices.Equal(got, tt.want) {
+			t.Errorf("packageSelectionArgs(%q) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestProfilePathResolver(t *testing.T) {
+	tmpDir := writeProjectFiles(t, map[string]string{
+		"go.mod":          "module example.com/mod\n\ngo 1.21\n",
+		"sub/pkg/file.go": "package pkg\n",
+	})
+
+	r := newProfilePathResolver(filepath.Join(tmpDir, "sub"))
+	tests := []struct {
+		name   string
+		want   string
+		wantOK bool
+	}{
+		{"example.com/mod/sub/pkg/file.go", filepath.Join("pkg", "file.go"), true},
+		{"example.com/mod/root.go", "", false},
+		{"example.com/other/file.go", "", false},
+		{filepath.Join(tmpDir, "sub", "abs.go"), "abs.go", true},
+	}
+
+	for _, tt := range tests {
+		got, ok := r.resolve(tt.name)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("resolve(%q) = (%q, %v), want (%q, %v)", tt.name, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestMergeCoverProfiles(t *testing.T) {
+	tests := []struct {
+		name      string
+		profiles  []string
+		wantMode  string
+		wantCount []int
+	}{
+		{
+			name: "set mode is or'ed",
+			profiles: []string{
+				"mode: set\nexample.com/p/a.go:3.14,5.2 1 1\nexample.com/p/a.go:7.14,9.2 1 0\n",
+				"mode: set\nexample.com/p/a.go:3.14,5.2 1 1\nexample.com/p/a.go:7.14,9.2 1 0\n",
+			},
+			wantMode:  "set",
+			wantCount: []int{1, 0},
+		},
+		{
+			name: "count mode is summed",
+			profiles: []string{
+				"mode: count\nexample.com/p/a.go:3.14,5.2 1 2\nexample.com/p/a.go:7.14,9.2 1 0\n",
+				"mode: count\nexample.com/p/a.go:3.14,5.2 1 3\nexample.com/p/a.go:7.14,9.2 1 4\n",
+			},
+			wantMode:  "count",
+			wantCount: []int{5, 4},
+		},
+		{
+			name: "count and atomic are summed",
+			profiles: []string{
+				"mode: atomic\nexample.com/p/a.go:3.14,5.2 1 2\n",
+				"mode: count\nexample.com/p/a.go:3.14,5.2 1 1\nexample.com/p/a.go:7.14,9.2 1 1\n",
+			},
+			wantMode:  "count",
+			wantCount: []int{3, 1},
+		},
+		{
+			name: "set with count yields set",
+			profiles: []string{
+				"mode: set\nexample.com/p/a.go:3.14,5.2 1 1\n",
+				"mode: count\nexample.com/p/a.go:3.14,5.2 1 6\nexample.com/p/a.go:7.14,9.2 1 2\n",
+			},
+			wantMode:  "set",
+			wantCount: []int{1, 1},
+		},
+		{
+			name: "concatenated shards",
+			profiles: []string{
+				"mode: count\nexample.com/p/a.go:3.14,5.2 1 1\nmode: count\nexample.com/p/a.go:3.14,5.2 1 1\nexample.com/p/a.go:7.14,9.2 1 0\n",
+			},
+			wantMode:  "count",
+			wantCount: []int{2, 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := make(map[string]string)
+			var paths []string
+			for i, content := range tt.profiles {
+				name := fmt.Sprintf("cover%d.out", i)
+				files[name] = content
+				paths = append(paths, name)
+			}
+			tmpDir := writeProjectFiles(t, files)
+			for i := range paths {
+				paths[i] = filepath.Join(tmpDir, paths[i])
+			}
+
+			profiles, err := mergeCoverProfiles(paths)
+			if err != nil {
+				t.Fatalf("mergeCoverProfiles failed: %v", err)
+			}
+			if len(profiles) != 1 {
+				t.Fatalf("Expected 1 file profile, got %d", len(profiles))
+			}
+			if profiles[0].Mode != tt.wantMode {
+				t.Errorf("Expected mode %q, got %q", tt.wantMode, profiles[0].Mode)
+			}
+			var counts []int
+			for _, b := range profiles[0].Blocks {
+				counts = append(counts, b.Count)
+			}
+			if !slices.Equal(counts, tt.wantCount) {
+				t.Errorf("Expected counts %v, got %v", tt.wantCount, counts)
+			}
+		})
+	}
+}
+
+func TestMaxCoverProfiles(t *testing.T) {
+	var sets [][]*cover.Profile
+	for _, profile := range []string{
+		"mode: count\nexample.com/p/a.go:3.14,5.2 1 2\nexample.com/p/a.go:7.14,9.2 1 0\n",
+		"mode: count\nexample.com/p/a.go:3.14,5.2 1 1\nexample.com/p/a.go:11.14,13.2 1 4\n",
+	} {
+		profiles, err := cover.ParseProfilesFromReader(strings.NewReader(profile))
+		if err != nil {
+			t.Fatalf("Failed to parse profile: %v", err)
+		}
+		sets = append(sets, profiles)
+	}
+
+	merged, err := maxCoverProfiles(sets...)
+	if err != nil {
+		t.Fatalf("maxCoverProfiles failed: %v", err)
+	}
+	var counts []int
+	for _, b := range merged[0].Blocks {
+		counts = append(counts, b.Count)
+	}
+	if want := []int{2, 0, 4}; len(merged) != 1 || merged[0].Mode != "count" || !slices.Equal(counts, want) {
+		t.Errorf("maxCoverProfiles() counts = %v, want %v", counts, want)
+	}
+}
+
+func TestMergeCoverProfiles_Errors(t *testing.T) {
+	tmpDir := writeProjectFiles(t, map[string]string{
+		"nomode.out": "example.com/p/a.go:3.14,5.2 1 1\n",
+		"empty.out":  "",
+		"mixed.out":  "mode: set\nexample.com/p/a.go:3.14,5.2 1 1\nmode: count\n",
+	})
+
+	for _, name := range []string{"nomode.out", "empty.out", "mixed.out", "missing.out"} {
+		if _, err := mergeCoverProfiles([]string{filepath.Join(tmpDir, name)}); err == nil {
+			t.Errorf("%s: expected error, got nil", name)
+		}
+	}
+}
+
+func TestLoadCoverProfiles(t *testing.T) {
+	// The test would fail if it ran, so coverage must come from the profiles only
+	tmpDir := writeProjectFiles(t, map[string]string{
+		"go.mod": "module testpkg\n\ngo 1.21\n",
+		"source.go": `package testpkg
+
+func Covered() int {
+	return 1
+}
+
+func Uncovered() int {
+	return 2
+}
+`,
+		"source_test.go": `package testpkg
 
 import "testing"
 
-func TestFoo(t *testing.T) {
-	if Foo() != 999 { // This will fail
-		t.Error("intentional failure")
+func TestFail(t *testing.T) { t.Fatal("tests must not run") }
+`,
+		"shard1.out": "mode: set\ntestpkg/source.go:3.20,5.2 1 1\ntestpkg/source.go:7.22,9.2 1 0\n",
+		"shard2.out": "mode: set\ntestpkg/source.go:3.20,5.2 1 0\ntestpkg/source.go:7.22,9.2 1 0\n",
+	})
+
+	coverage, err := loadCoverProfiles(tmpDir, []string{filepath.Join(tmpDir, "shard1.out"), filepath.Join(tmpDir, "shard2.out")}, false)
+	if err != nil {
+		t.Fatalf("loadCoverProfiles failed: %v", err)
+	}
+
+	byPosition := coverage.byPosition()
+	if byPosition[funcPosition{File: "source.go", Line: 3}] != 100 || byPosition[funcPosition{File: "source.go", Line: 7}] != 0 {
+		t.Errorf("Unexpected coverage: %v", byPosition)
 	}
 }
-`
-	err = os.WriteFile(filepath.Join(tmpDir, "source_test.go"), []byte(testContent), 0644)
+
+func TestAnalyzeProject_CoverageJoinedByPosition(t *testing.T) {
+	// New and Close exist several times; only the ones exercised by Run are covered
+	tmpDir := writeProjectFiles(t, map[string]string{
+		"go.mod": "module example.com/multi\n\ngo 1.21\n",
+		"a/a.go": `package a
+
+type File struct{}
+
+func (f *File) Close() error { return nil }
+
+type Conn struct{}
+
+func (c *Conn) Close() error { return nil }
+
+func New() *File { return &File{} }
+
+func Run() error { return New().Close() }
+`,
+		"a/a_test.go": `package a
+
+import "testing"
+
+func TestRun(t *testing.T) {
+	if err := Run(); err != nil {
+		t.Fatal(err)
+	}
+}
+`,
+		"b/b.go": `package b
+
+func New() int { return 1 }
+`,
+	})
+
+	coverage, err := runCoverage(tmpDir, goTestConfig{}, false)
 	if err != nil {
-		t.Fatalf("Failed to write test file: %v", err)
+		t.Fatalf("runCoverage failed: %v", err)
+	}
+	result, err := analyzeProject(tmpDir, analysisOptions{}, coverage)
+	if err != nil {
+		t.Fatalf("analyzeProject failed: %v", err)
 	}
 
-	// analyzeCoverage should return an error when tests fail
-	_, err = analyzeCoverage(tmpDir, 80, false)
-	if err == nil {
-		t.Error("Expected error when tests fail, got nil")
+	verdicts := make(map[string]Verdict)
+	for _, f := range result.Functions {
+		verdicts[fmt.Sprintf("%s:%s.%s", f.File, f.Receiver, f.Name)] = f.Verdict
+	}
+	want := map[string]Verdict{
+		filepath.Join("a", "a.go") + ":File.Close": VerdictCoverageOnly,
+		filepath.Join("a", "a.go") + ":Conn.Close": VerdictUntested,
+		filepath.Join("a", "a.go") + ":.New":       VerdictCoverageOnly,
+		filepath.Join("a", "a.go") + ":.Run":       VerdictDirectCall,
+		filepath.Join("b", "b.go") + ":.New":       VerdictUntested,
+	}
+	if !reflect.DeepEqual(verdicts, want) {
+		t.Errorf("Verdicts = %v, want %v", verdicts, want)
 	}
 }
 
-func TestAnalyzeCoverage_NoGoModule(t *testing.T) {
-	// Create a directory without go.mod (will fail go test)
-	tmpDir, err := os.MkdirTemp("", "test-nomod-*")
+func TestUnionCoverProfiles(t *testing.T) {
+	unit := []*cover.Profile{{FileName: "example.com/p/a.go", Mode: "set", Blocks: []cover.ProfileBlock{
+		{StartLine: 3, StartCol: 14, EndLine: 5, EndCol: 2, NumStmt: 1, Count: 1},
+		{StartLine: 7, StartCol: 14, EndLine: 9, EndCol: 2, NumStmt: 1, Count: 0},
+	}}}
+	integration := []*cover.Profile{
+		{FileName: "example.com/p/a.go", Mode: "set", Blocks: []cover.ProfileBlock{
+			{StartLine: 3, StartCol: 14, EndLine: 5, EndCol: 2, NumStmt: 1, Count: 1},
+			{StartLine: 7, StartCol: 14, EndLine: 9, EndCol: 2, NumStmt: 1, Count: 1},
+		}},
+		{FileName: "example.com/p/cmd/main.go", Mode: "set", Blocks: []cover.ProfileBlock{
+			{StartLine: 3, StartCol: 13, EndLine: 5, EndCol: 2, NumStmt: 1, Count: 1},
+		}},
+	}
+
+	profiles, err := unionCoverProfiles(unit, integration)
 	if err != nil {
-		t.Fatalf("Failed to create temp dir: %v", err)
+		t.Fatalf("unionCoverProfiles failed: %v", err)
 	}
-	defer os.RemoveAll(tmpDir)
+	got := make(map[string][]int)
+	for _, p := range profiles {
+		if p.Mode != "set" {
+			t.Errorf("%s: mode = %q, want set", p.FileName, p.Mode)
+		}
+		for _, b := range p.Blocks {
+			got[p.FileName] = append(got[p.FileName], b.Count)
+		}
+	}
+	want := map[string][]int{"example.com/p/a.go": {1, 1}, "example.com/p/cmd/main.go": {1}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Counts = %v, want %v", got, want)
+	}
+}
 
-	// Create just a Go file, no go.mod
-	sourceContent := `package testpkg
+func TestAddIntegrationCoverage(t *testing.T) {
+	tmpDir := writeProjectFiles(t, map[string]string{
+		"go.mod": "module example.com/e2e\n\ngo 1.21\n",
+		"greet/greet.go": `package greet
+
+func Hello() string { return "hello" }
+
+func Shout(s string) string {
+	return s + "!"
+}
+
+func Unused() {}
+`,
+		"greet/greet_test.go": `package greet
+
+import "testing"
+
+func TestHello(t *testing.T) {
+	if Hello() != "hello" {
+		t.Fatal("unexpected greeting")
+	}
+}
+`,
+		"cmd/greet/main.go": `package main
+
+import (
+	"fmt"
+
+	"example.com/e2e/greet"
+)
+
+func main() {
+	fmt.Println(greet.Shout(greet.Hello()))
+}
+`,
+	})
+
+	// Build and run the binary with coverage, as an end-to-end test would
+	binPath := filepath.Join(t.TempDir(), "greet")
+	build := exec.Command("go", "build", "-cover", "-o", binPath, "./cmd/greet")
+	build.Dir = tmpDir
+	if out, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("go build -cover failed: %v\n%s", err, out)
+	}
+	covDir := t.TempDir()
+	run := exec.Command(binPath)
+	run.Env = append(os.Environ(), "GOCOVERDIR="+covDir)
+	if out, err := run.CombinedOutput(); err != nil {
+		t.Fatalf("Running the binary failed: %v\n%s", err, out)
+	}
+
+	unit, err := runCoverage(tmpDir, goTestConfig{}, false)
+	if err != nil {
+		t.Fatalf("runCoverage failed: %v", err)
+	}
+	coverage, err := addIntegrationCoverage(tmpDir, unit, []string{covDir}, false)
+	if err != nil {
+		t.Fatalf("addIntegrationCoverage failed: %v", err)
+	}
+
+	result, err := analyzeProject(tmpDir, analysisOptions{}, coverage)
+	if err != nil {
+		t.Fatalf("analyzeProject failed: %v", err)
+	}
+	verdicts := make(map[string]Verdict)
+	for _, f := range result.Functions {
+		verdicts[f.Name] = f.Verdict
+	}
+	want := map[string]Verdict{
+		"Hello":  VerdictDirectCall,
+		"Shout":  VerdictIntegrationOnly,
+		"Unused": VerdictUntested,
+	}
+	if !reflect.DeepEqual(verdicts, want) {
+		t.Errorf("Verdicts = %v, want %v", verdicts, want)
+	}
+	if len(result.IntegrationOnlyFuncs) != 1 || result.IntegrationOnlyFuncs[0].Name != "Shout" {
+		t.Errorf("IntegrationOnlyFuncs = %v, want only Shout", result.IntegrationOnlyFuncs)
+	}
+
+	// Without unit coverage, covered functions are not labelled integration-only
+	integrationOnly, err := addIntegrationCoverage(tmpDir, nil, []string{covDir}, false)
+	if err != nil {
+		t.Fatalf("addIntegrationCoverage without unit coverage failed: %v", err)
+	}
+	for _, f := range integrationOnly.funcs {
+		if f.IntegrationOnly {
+			t.Errorf("%s labelled integration-only without unit coverage", f.Name)
+		}
+		if f.Name == "Shout" && f.Coverage == 0 {
+			t.Error("Expected Shout to be covered by the integration run")
+		}
+	}
+
+	if _, err := addIntegrationCoverage(tmpDir, unit, []string{filepath.Join(tmpDir, "missing")}, false); err == nil {
+		t.Error("Expected error for a missing coverage directory")
+	}
+}
+
+func TestParseTagSets(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []string
+	}{
+		{"", nil},
+		{"integration", []string{"integration"}},
+		{"integration,e2e", []string{"integration,e2e"}},
+		{"default; integration", []string{"", "integration"}},
+		{";integration", []string{"", "integration"}},
+	}
+
+	for _, tt := range tests {
+		if got := parseTagSets(tt.value); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseTagSets(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestCompareTagSets(t *testing.T) {
+	c := &coverageData{
+		funcs: []funcCoverage{
+			{File: "a.go", Line: 3, Name: "Same"},
+			{File: "a.go", Line: 9, Name: "Query", Receiver: "Store"},
+			{File: "db.go", Line: 5, Name: "Connect"},
+		},
+		tagSets: []tagSetCoverage{
+			{tags: "", coverage: map[funcPosition]float64{{File: "a.go", Line: 3}: 100, {File: "a.go", Line: 9}: 0}},
+			{tags: "integration", coverage: map[funcPosition]float64{{File: "a.go", Line: 3}: 100, {File: "a.go", Line: 9}: 80, {File: "db.go", Line: 5}: 50}},
+		},
+	}
+
+	names, diffs := c.compareTagSets()
+	if want := []string{"default", "integration"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("names = %v, want %v", names, want)
+	}
+	want := []TagSetCoverage{
+		{File: "a.go", Line: 9, Name: "Query", Receiver: "Store", Coverage: []float64{0, 80}},
+		{File: "db.go", Line: 5, Name: "Connect", Coverage: []float64{0, 50}},
+	}
+	if !reflect.DeepEqual(diffs, want) {
+		t.Errorf("diffs = %v, want %v", diffs, want)
+	}
+
+	if names, diffs := (&coverageData{funcs: c.funcs}).compareTagSets(); names != nil || diffs != nil {
+		t.Errorf("Expected no comparison for a single run, got %v, %v", names, diffs)
+	}
+}
+
+func TestRunCoverage_GoTestConfig(t *testing.T) {
+	tmpDir := writeProjectFiles(t, map[string]string{
+		"go.mod": "module example.com/tagged\n\ngo 1.21\n",
+		"store.go": `package store
+
+func Get() int { return 1 }
+
+func Query() int { return 2 }
+
+func Slow() int { return 3 }
+`,
+		"store_test.go": `package store
+
+import (
+	"os"
+	"testing"
+)
+
+func TestGet(t *testing.T) {
+	if os.Getenv("STORE_DSN") == "" {
+		t.Fatal("STORE_DSN not set")
+	}
+	Get()
+}
+
+func TestSlow(t *testing.T) {
+	if testing.Short() {
+		t.Skip("slow")
+	}
+	Slow()
+}
+`,
+		"store_integration_test.go": `//go:build integration
+
+package store
+
+import "testing"
+
+func TestQuery(t *testing.T) {
+	Query()
+}
+`,
+	})
 
-func Foo() {}
+	cfg := goTestConfig{
+		args:    []string{"-short"},
+		env:     []string{"STORE_DSN=memory"},
+		tagSets: []string{"", "integration"},
+	}
+	coverage, err := runCoverage(tmpDir, cfg, false)
+	if err != nil {
+		t.Fatalf("runCoverage failed: %v", err)
+	}
+
+	byPosition := coverage.byPosition()
+	want := map[funcPosition]float64{
+		{File: "store.go", Line: 3}: 100, // needs the environment
+		{File: "store.go", Line: 5}: 100, // integration tag set only
+		{File: "store.go", Line: 7}: 0,   // skipped by -short
+	}
+	if !reflect.DeepEqual(byPosition, want) {
+		t.Errorf("Union coverage = %v, want %v", byPosition, want)
+	}
+
+	// TestGet runs in both tag sets, but its hits are not counted twice
+	var barely []string
+	for _, f := range coverage.barelyExercised(1) {
+		barely = append(barely, f.Name)
+	}
+	if want := []string{"Get", "Query"}; !slices.Equal(barely, want) {
+		t.Errorf("barelyExercised(1) = %v, want %v", barely, want)
+	}
+
+	_, diffs := coverage.compareTagSets()
+	if len(diffs) != 1 || diffs[0].Name != "Query" || !reflect.DeepEqual(diffs[0].Coverage, []float64{0, 100}) {
+		t.Errorf("Tag set differences = %v, want only Query (0%% vs 100%%)", diffs)
+	}
+
+	// Without the environment the tests fail
+	coverage, err = runCoverage(tmpDir, goTestConfig{}, false)
+	if err != nil {
+		t.Fatalf("runCoverage failed: %v", err)
+	}
+	wantFailed := []FailedPackage{{Package: "example.com/tagged", Dir: ".", Tests: []string{"TestGet"}}}
+	if !reflect.DeepEqual(coverage.failed, wantFailed) {
+		t.Errorf("Failed packages = %v, want %v", coverage.failed, wantFailed)
+	}
+}
+
+func TestParseTestEvents(t *testing.T) {
+	output := `{"Action":"start","Package":"example.com/app/a"}
+{"Action":"run","Package":"example.com/app/a","Test":"TestA"}
+{"Action":"fail","Package":"example.com/app/a","Test":"TestA/sub"}
+{"Action":"fail","Package":"example.com/app/a","Test":"TestA"}
+{"Action":"fail","Package":"example.com/app/a"}
+{"Action":"pass","Package":"example.com/app/b"}
+# example.com/app/c
+{"Action":"fail","Package":"example.com/app/c","FailedBuild":"example.com/app/c [example.com/app/c.test]"}
 `
-	err = os.WriteFile(filepath.Join(tmpDir, "source.go"), []byte(sourceContent), 0644)
+	want := []FailedPackage{
+		{Package: "example.com/app/a", Tests: []string{"TestA/sub", "TestA"}},
+		{Package: "example.com/app/c", BuildFailed: true},
+	}
+	if got := parseTestEvents([]byte(output)); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseTestEvents() = %v, want %v", got, want)
+	}
+}
+
+func TestMergeFailedPackages(t *testing.T) {
+	a := []FailedPackage{{Package: "example.com/app/b", Tests: []string{"TestB"}}}
+	b := []FailedPackage{
+		{Package: "example.com/app/a", BuildFailed: true},
+		{Package: "example.com/app/b", Tests: []string{"TestB", "TestIntegration"}},
+	}
+	want := []FailedPackage{
+		{Package: "example.com/app/a", BuildFailed: true},
+		{Package: "example.com/app/b", Tests: []string{"TestB", "TestIntegration"}},
+	}
+	if got := mergeFailedPackages(a, b); !reflect.DeepEqual(got, want) {
+		t.Errorf("mergeFailedPackages() = %v, want %v", got, want)
+	}
+}
+
+func TestRunCoverage_FailingPackages(t *testing.T) {
+	tmpDir := writeProjectFiles(t, map[string]string{
+		"go.mod": "module example.com/partial\n\ngo 1.21\n",
+		"ok/ok.go": `package ok
+
+func Run() int { return helper() }
+
+func helper() int { return 1 }
+`,
+		"ok/ok_test.go": `package ok
+
+import "testing"
+
+func TestRun(t *testing.T) {
+	if Run() != 1 {
+		t.Fatal("unexpected result")
+	}
+}
+`,
+		"flaky/flaky.go": `package flaky
+
+func Do() int { return inner() }
+
+func inner() int { return 2 }
+`,
+		"flaky/flaky_test.go": `package flaky
+
+import "testing"
+
+func TestDo(t *testing.T) {
+	if Do() != 3 {
+		t.Fatal("unexpected result")
+	}
+}
+`,
+		"broken/broken.go": `package broken
+
+func Value() int { return 3 }
+`,
+		"broken/broken_test.go": `package broken
+
+import "testing"
+
+func TestValue(t *testing.T) {
+	var s string = 3
+	_ = s
+}
+`,
+	})
+
+	coverage, err := runCoverage(tmpDir, goTestConfig{}, false)
 	if err != nil {
-		t.Fatalf("Failed to write source file: %v", err)
+		t.Fatalf("runCoverage failed: %v", err)
+	}
+
+	wantFailed := []FailedPackage{
+		{Package: "example.com/partial/broken", Dir: "broken", BuildFailed: true},
+		{Package: "example.com/partial/flaky", Dir: "flaky", Tests: []string{"TestDo"}},
+	}
+	if !reflect.DeepEqual(coverage.failed, wantFailed) {
+		t.Errorf("Failed packages = %v, want %v", coverage.failed, wantFailed)
+	}
+	for _, f := range coverage.funcs {
+		if filepath.Dir(f.File) != "ok" {
+			t.Errorf("Coverage of %s in a failed package should be dropped", f.File)
+		}
+	}
+
+	result, err := analyzeProject(tmpDir, analysisOptions{}, coverage)
+	if err != nil {
+		t.Fatalf("analyzeProject failed: %v", err)
+	}
+	verdicts := make(map[string]Verdict)
+	for _, f := range result.Functions {
+		verdicts[f.Name] = f.Verdict
+	}
+	want := map[string]Verdict{
+		"Run":    VerdictDirectCall,
+		"helper": VerdictCoverageOnly,
+		"Do":     VerdictDirectCall,
+		"inner":  VerdictCoverageUnknown,
+		"Value":  VerdictCoverageUnknown,
+	}
+	if !reflect.DeepEqual(verdicts, want) {
+		t.Errorf("Verdicts = %v, want %v", verdicts, want)
+	}
+}
+
+func TestRunCoverage_CoverPkg(t *testing.T) {
+	tmpDir := writeProjectFiles(t, map[string]string{
+		"go.mod": "module example.com/shared\n\ngo 1.21\n",
+		"lib/lib.go": `package lib
+
+func Retry(n int) int {
+	if n > 1 {
+		return n
+	}
+	return 1
+}
+
+func Unused() {}
+`,
+		"lib/lib_test.go": `package lib
+
+import "testing"
+
+func TestRetry(t *testing.T) {
+	if Retry(0) != 1 {
+		t.Fatal("unexpected result")
+	}
+}
+`,
+		"service/service.go": `package service
+
+import "example.com/shared/lib"
+
+func Handle() int { return lib.Retry(3) }
+`,
+		"service/service_test.go": `package service
+
+import "testing"
+
+func TestHandle(t *testing.T) {
+	if Handle() != 3 {
+		t.Fatal("unexpected result")
+	}
+}
+`,
+	})
+
+	// Without -coverpkg only the package's own tests count
+	coverage, err := runCoverage(tmpDir, goTestConfig{}, false)
+	if err != nil {
+		t.Fatalf("runCoverage failed: %v", err)
+	}
+	retry := funcPosition{File: filepath.Join("lib", "lib.go"), Line: 3}
+	if cov := coverage.byPosition()[retry]; cov != 200.0/3 {
+		t.Errorf("Retry coverage without -coverpkg = %.1f, want 66.7", cov)
+	}
+
+	coverage, err = runCoverage(tmpDir, goTestConfig{coverPkg: "./..."}, false)
+	if err != nil {
+		t.Fatalf("runCoverage failed: %v", err)
+	}
+	if cov := coverage.byPosition()[retry]; cov != 100 {
+		t.Errorf("Retry coverage with -coverpkg = %.1f, want 100", cov)
 	}
 
-	// analyzeCoverage should return an error
-	_, err = analyzeCoverage(tmpDir, 80, false)
-	if err == nil {
-		t.Error("Expected error for directory without go.mod, got nil")
+	want := []CrossPackageFunc{{
+		File:     filepath.Join("lib", "lib.go"),
+		Line:     3,
+		Name:     "Retry",
+		Coverage: 100,
+		Packages: []string{"example.com/shared/lib", "example.com/shared/service"},
+	}}
+	if got := coverage.crossPackage(); !reflect.DeepEqual(got, want) {
+		t.Errorf("crossPackage() = %v, want %v", got, want)
 	}
 }
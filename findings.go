@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Kinds of findings of the machine-readable output formats
+const (
+	findingUntested    = "untested-function"
+	findingMisplaced   = "misplaced-test"
+	findingLowCoverage = "low-coverage"
+)
+
+// findingKinds are the kinds of findings, in report order
+var findingKinds = []string{findingUntested, findingMisplaced, findingLowCoverage}
+
+// finding is a single untested function, misplaced test or low coverage
+// function, located at a file relative to the analyzed directory
+type finding struct {
+	Kind     string
+	Subject  string // function or test the finding is about, e.g. (Server).Start
+	File     string
+	Line     int
+	Message  string
+	Identity []string // identifies the finding independently of its line
+}
+
+// collectFindings returns the functions without tests, misplaced tests and low
+// coverage functions of the result as findings, in this order
+func collectFindings(result *AnalysisResult) []finding {
+	var findings []finding
+	for _, f := range result.FunctionsWithoutTests {
+		desc := funcDescription(f.Receiver, f.Name)
+		findings = append(findings, finding{
+			Kind:     findingUntested,
+			Subject:  desc,
+			File:     f.File,
+			Line:     f.Line,
+			Message:  fmt.Sprintf("%s is not called from any test", desc),
+			Identity: []string{f.File, f.Package, desc},
+		})
+	}
+	for _, mt := range result.MisplacedTests {
+		findings = append(findings, finding{
+			Kind:     findingMisplaced,
+			Subject:  mt.Test.Name,
+			File:     mt.ActualFile,
+			Line:     mt.Test.Line,
+			Message:  fmt.Sprintf("%s belongs in %s", mt.Test.Name, mt.ExpectedFile),
+			Identity: []string{mt.ActualFile, mt.Test.Name},
+		})
+	}
+	for _, f := range result.LowCoverageFuncs {
+		desc := funcDescription(f.Receiver, f.Name)
+		msg := fmt.Sprintf("%s has %.1f%% statement coverage, below %.1f%%", desc, f.Coverage, f.Threshold)
+		if len(f.Uncovered) > 0 {
+			ranges := make([]string, len(f.Uncovered))
+			for i, r := range f.Uncovered {
+				ranges[i] = r.String()
+			}
+			msg += fmt.Sprintf(" (uncovered lines %s)", strings.Join(ranges, ", "))
+		}
+		findings = append(findings, finding{
+			Kind:     findingLowCoverage,
+			Subject:  desc,
+			File:     f.File,
+			Line:     f.Line,
+			Message:  msg,
+			Identity: []string{f.File, desc},
+		})
+	}
+	return findings
+}
+
+// funcDescription formats a function as in the text report, e.g. (Server).Start
+func funcDescription(receiver, name string) string {
+	if receiver != "" {
+		return fmt.Sprintf("(%s).%s", receiver, name)
+	}
+	return name
+}
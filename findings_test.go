@@ -0,0 +1,29 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCollectFindings(t *testing.T) {
+	result := &AnalysisResult{
+		LowCoverageFuncs: []LowCoverageFunc{
+			{File: "run.go", Line: 10, Name: "Run", Receiver: "Server", Coverage: 25, Threshold: 80, Uncovered: []LineRange{{12, 14}, {20, 20}}},
+		},
+		MisplacedTests: []MisplacedTest{
+			{Test: TestInfo{Name: "TestParse", Line: 7}, ActualFile: "util_test.go", ExpectedFile: "parse_test.go"},
+		},
+		FunctionsWithoutTests: []FuncInfo{
+			{Name: "Parse", File: "parse.go", Line: 3, Package: "example.com/p"},
+		},
+	}
+
+	want := []finding{
+		{Kind: findingUntested, Subject: "Parse", File: "parse.go", Line: 3, Message: "Parse is not called from any test", Identity: []string{"parse.go", "example.com/p", "Parse"}},
+		{Kind: findingMisplaced, Subject: "TestParse", File: "util_test.go", Line: 7, Message: "TestParse belongs in parse_test.go", Identity: []string{"util_test.go", "TestParse"}},
+		{Kind: findingLowCoverage, Subject: "(Server).Run", File: "run.go", Line: 10, Message: "(Server).Run has 25.0% statement coverage, below 80.0% (uncovered lines 12-14, 20)", Identity: []string{"run.go", "(Server).Run"}},
+	}
+	if got := collectFindings(result); !reflect.DeepEqual(got, want) {
+		t.Errorf("collectFindings() = %v, want %v", got, want)
+	}
+}
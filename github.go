@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// githubTitles are the annotation titles of the kinds of findings
+var githubTitles = map[string]string{
+	findingUntested:    "Untested function",
+	findingMisplaced:   "Misplaced test",
+	findingLowCoverage: "Low coverage",
+}
+
+// parseErrorKinds parses the comma-separated finding kinds of -error-on
+func parseErrorKinds(s string) (map[string]bool, error) {
+	kinds := make(map[string]bool)
+	for _, kind := range strings.Split(s, ",") {
+		kind = strings.TrimSpace(kind)
+		if kind == "" {
+			continue
+		}
+		if !slices.Contains(findingKinds, kind) {
+			return nil, fmt.Errorf("unknown finding kind %q (want %s)", kind, strings.Join(findingKinds, ", "))
+		}
+		kinds[kind] = true
+	}
+	return kinds, nil
+}
+
+// writeGitHubAnnotations writes the functions without tests, misplaced tests
+// and low coverage functions as GitHub Actions workflow commands, annotating
+// the kinds in errorKinds as errors and the others as warnings. File paths are
+// relative to the working directory, which is the repository root in a
+// workflow step.
+func writeGitHubAnnotations(w io.Writer, result *AnalysisResult, baseDir string, errorKinds map[string]bool) error {
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	for _, f := range collectFindings(result) {
+		path := filepath.Join(baseDir, f.File)
+		if rel, err := filepath.Rel(wd, path); err == nil && !strings.HasPrefix(rel, "..") {
+			path = rel
+		}
+
+		command := "warning"
+		if errorKinds[f.Kind] {
+			command = "error"
+		}
+		if _, err := fmt.Fprintf(w, "::%s file=%s,line=%d,title=%s::%s\n", command,
+			escapeGitHubProperty(filepath.ToSlash(path)), f.Line,
+			escapeGitHubProperty(githubTitles[f.Kind]), escapeGitHubData(f.Message)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// escapeGitHubData escapes the message of a workflow command
+func escapeGitHubData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// escapeGitHubProperty escapes a property value of a workflow command
+func escapeGitHubProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
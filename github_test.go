@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseErrorKinds(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    map[string]bool
+		wantErr bool
+	}{
+		{"", map[string]bool{}, false},
+		{"untested-function", map[string]bool{findingUntested: true}, false},
+		{"low-coverage, misplaced-test", map[string]bool{findingLowCoverage: true, findingMisplaced: true}, false},
+		{"untested", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := parseErrorKinds(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseErrorKinds(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseErrorKinds(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteGitHubAnnotations(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := &AnalysisResult{
+		FunctionsWithoutTests: []FuncInfo{
+			{Name: "Start", Receiver: "Server", File: "server.go", Line: 12},
+		},
+		MisplacedTests: []MisplacedTest{
+			{Test: TestInfo{Name: "TestParse", Line: 7}, ActualFile: "util_test.go", ExpectedFile: "parse_test.go"},
+		},
+		LowCoverageFuncs: []LowCoverageFunc{
+			{File: "run,fast.go", Line: 10, Name: "Run", Coverage: 25, Threshold: 80},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		baseDir    string
+		errorKinds map[string]bool
+		want       []string
+	}{
+		{
+			name:    "warnings",
+			baseDir: filepath.Join(wd, "pkg"),
+			want: []string{
+				"::warning file=pkg/server.go,line=12,title=Untested function::(Server).Start is not called from any test",
+				"::warning file=pkg/util_test.go,line=7,title=Misplaced test::TestParse belongs in parse_test.go",
+				"::warning file=pkg/run%2Cfast.go,line=10,title=Low coverage::Run has 25.0%25 statement coverage, below 80.0%25",
+			},
+		},
+		{
+			name:       "errors",
+			baseDir:    wd,
+			errorKinds: map[string]bool{findingUntested: true},
+			want: []string{
+				"::error file=server.go,line=12,title=Untested function::(Server).Start is not called from any test",
+				"::warning file=util_test.go,line=7,title=Misplaced test::TestParse belongs in parse_test.go",
+				"::warning file=run%2Cfast.go,line=10,title=Low coverage::Run has 25.0%25 statement coverage, below 80.0%25",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := writeGitHubAnnotations(&buf, result, tt.baseDir, tt.errorKinds); err != nil {
+				t.Fatalf("writeGitHubAnnotations failed: %v", err)
+			}
+			got := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("writeGitHubAnnotations() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+	"sort"
+)
+
+// implementation is a concrete module method implementing an interface method
+type implementation struct {
+	typeKey   string // qualified name of the concrete type
+	methodKey string // qualified key of the concrete method
+}
+
+// analyzeInterfaceDispatch resolves the interface method calls made from tests
+// to their concrete implementations in the module. An implementation is
+// exercised if a test calls the interface method and also uses the concrete
+// type (e.g. constructs it or receives it from a constructor). It returns the
+// keys of exercised implementations and the implementations of called interface
+// methods that are neither exercised nor otherwise tested.
+func analyzeInterfaceDispatch(parsed *parseResult, testedFuncs map[string]bool) (map[string]bool, []UnexercisedImpl) {
+	impls := findInterfaceImplementations(parsed.ifaceMethods, parsed.concreteTypes)
+
+	exercised := make(map[string]bool)
+	for _, tests := range parsed.fileTests {
+		for _, test := range tests {
+			usedTypes := make(map[string]bool)
+			for _, t := range test.ConcreteTypes {
+				usedTypes[t] = true
+			}
+			for _, ifaceKey := range test.InterfaceCalls {
+				for _, impl := range impls[ifaceKey] {
+					if usedTypes[impl.typeKey] {
+						exercised[impl.methodKey] = true
+					}
+				}
+			}
+		}
+	}
+
+	funcsByKey := make(map[string]FuncInfo)
+	for _, funcs := range parsed.fileFunctions {
+		for _, f := range funcs {
+			funcsByKey[f.Key()] = f
+		}
+	}
+
+	var unexercised []UnexercisedImpl
+	for ifaceKey, ifaceImpls := range impls {
+		for _, impl := range ifaceImpls {
+			if exercised[impl.methodKey] || testedFuncs[impl.methodKey] {
+				continue
+			}
+			f, ok := funcsByKey[impl.methodKey]
+			if !ok {
+				continue
+			}
+			unexercised = append(unexercised, UnexercisedImpl{
+				Func:      f,
+				Interface: interfaceMethodName(parsed.ifaceMethods[ifaceKey]),
+			})
+		}
+	}
+
+	sort.Slice(unexercised, func(i, j int) bool {
+		if unexercised[i].Func.File != unexercised[j].Func.File {
+			return unexercised[i].Func.File < unexercised[j].Func.File
+		}
+		if unexercised[i].Func.Line != unexercised[j].Func.Line {
+			return unexercised[i].Func.Line < unexercised[j].Func.Line
+		}
+		return unexercised[i].Interface < unexercised[j].Interface
+	})
+
+	return exercised, unexercised
+}
+
+// findInterfaceImplementations maps each interface method key to the concrete
+// module methods implementing it
+func findInterfaceImplementations(ifaceMethods map[string]*types.Func, concreteTypes map[string]*types.Named) map[string][]implementation {
+	typeKeys := make([]string, 0, len(concreteTypes))
+	for key := range concreteTypes {
+		typeKeys = append(typeKeys, key)
+	}
+	sort.Strings(typeKeys)
+
+	impls := make(map[string][]implementation)
+	for ifaceKey, method := range ifaceMethods {
+		iface, ok := method.Signature().Recv().Type().Underlying().(*types.Interface)
+		if !ok {
+			continue
+		}
+		for _, typeKey := range typeKeys {
+			named := concreteTypes[typeKey]
+			if !implementsInterface(named, iface) {
+				continue
+			}
+			sel := types.NewMethodSet(types.NewPointer(named)).Lookup(method.Pkg(), method.Name())
+			impls[ifaceKey] = append(impls[ifaceKey], implementation{
+				typeKey:   typeKey,
+				methodKey: funcObjectKey(sel.Obj().(*types.Func)),
+			})
+		}
+	}
+	return impls
+}
+
+// implementsInterface reports whether t or *t has all methods of iface.
+// Signatures are compared textually because the interface and the concrete
+// type may come from different type-checking passes (e.g. export data).
+func implementsInterface(t *types.Named, iface *types.Interface) bool {
+	if iface.NumMethods() == 0 {
+		return false
+	}
+	mset := types.NewMethodSet(types.NewPointer(t))
+	for i := 0; i < iface.NumMethods(); i++ {
+		m := iface.Method(i)
+		sel := mset.Lookup(m.Pkg(), m.Name())
+		if sel == nil {
+			return false
+		}
+		fn, ok := sel.Obj().(*types.Func)
+		if !ok || signatureString(fn.Signature()) != signatureString(m.Signature()) {
+			return false
+		}
+		if !ast.IsExported(m.Name()) && fn.Pkg().Path() != m.Pkg().Path() {
+			return false
+		}
+	}
+	return true
+}
+
+// signatureString returns the parameters and results of a signature with
+// package-path qualified type names, ignoring the receiver
+func signatureString(sig *types.Signature) string {
+	plain := types.NewSignatureType(nil, nil, nil, sig.Params(), sig.Results(), sig.Variadic())
+	return types.TypeString(plain, func(p *types.Package) string { return p.Path() })
+}
+
+// interfaceMethodName returns the report name of an interface method, e.g. "(Store).Get"
+func interfaceMethodName(fn *types.Func) string {
+	return fmt.Sprintf("(%s).%s", funcRecvTypeName(fn), fn.Name())
+}
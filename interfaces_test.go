@@ -0,0 +1,122 @@
+package main
+
+import (
+	"go/types"
+	"testing"
+)
+
+// interfaceProject is a module where Store.Get is called from a test with only memStore in use
+var interfaceProject = map[string]string{
+	"go.mod": "module example.com/store\n\ngo 1.21\n",
+	"store.go": `package store
+
+type Store interface {
+	Get(id string) (string, error)
+}
+
+type memStore struct{}
+
+func NewMemStore() *memStore { return &memStore{} }
+
+func (m *memStore) Get(id string) (string, error) { return id, nil }
+
+type pgStore struct{}
+
+func (p *pgStore) Get(id string) (string, error) { return "", nil }
+
+type fileStore struct{}
+
+func (f fileStore) Get(id string) (string, error) { return "", nil }
+
+type notAStore struct{}
+
+func (n notAStore) Get(id int) string { return "" }
+`,
+	"store_test.go": `package store
+
+import "testing"
+
+func TestGet(t *testing.T) {
+	var s Store = NewMemStore()
+	s.Get("a")
+
+	f := fileStore{}
+	f.Get("b")
+}
+`,
+}
+
+func TestAnalyzeInterfaceDispatch(t *testing.T) {
+	tmpDir := writeProjectFiles(t, interfaceProject)
+	parsed, err := parseProjectFiles(tmpDir, false, false)
+	if err != nil {
+		t.Fatalf("parseProjectFiles failed: %v", err)
+	}
+
+	testedFuncs := buildTestedFuncsMap(parsed.fileTests)
+	exercised, unexercised := analyzeInterfaceDispatch(parsed, testedFuncs)
+
+	if !exercised["(example.com/store.memStore).Get"] {
+		t.Errorf("Expected memStore.Get to be exercised through Store, got %v", exercised)
+	}
+	if exercised["(example.com/store.pgStore).Get"] {
+		t.Error("pgStore.Get should not be exercised")
+	}
+
+	// fileStore.Get is called directly and notAStore does not implement Store
+	if len(unexercised) != 1 {
+		t.Fatalf("Expected 1 unexercised implementation, got %v", unexercised)
+	}
+	if unexercised[0].Func.Receiver != "pgStore" || unexercised[0].Interface != "(Store).Get" {
+		t.Errorf("Unexpected unexercised implementation %+v", unexercised[0])
+	}
+}
+
+func TestAnalyzeProject_InterfaceDispatch(t *testing.T) {
+	tmpDir := writeProjectFiles(t, interfaceProject)
+	result, err := analyzeProject(tmpDir, analysisOptions{}, nil)
+	if err != nil {
+		t.Fatalf("analyzeProject failed: %v", err)
+	}
+
+	untested := make(map[string]bool)
+	for _, f := range result.FunctionsWithoutTests {
+		untested[f.Receiver+"."+f.Name] = true
+	}
+	if untested["memStore.Get"] {
+		t.Error("memStore.Get should be tested through the Store interface")
+	}
+	if !untested["pgStore.Get"] {
+		t.Error("pgStore.Get should be reported without tests")
+	}
+	if len(result.UnexercisedImpls) != 1 {
+		t.Errorf("Expected 1 unexercised implementation, got %v", result.UnexercisedImpls)
+	}
+}
+
+func TestImplementsInterface(t *testing.T) {
+	pkg := types.NewPackage("example.com/p", "p")
+	str := types.Typ[types.String]
+
+	newSig := func(param types.Type) *types.Signature {
+		params := types.NewTuple(types.NewVar(0, pkg, "x", param))
+		return types.NewSignatureType(nil, nil, nil, params, nil, false)
+	}
+	iface := types.NewInterfaceType([]*types.Func{types.NewFunc(0, pkg, "Do", newSig(str))}, nil)
+	iface.Complete()
+
+	newNamed := func(name string, param types.Type) *types.Named {
+		named := types.NewNamed(types.NewTypeName(0, pkg, name, nil), types.NewStruct(nil, nil), nil)
+		recv := types.NewVar(0, pkg, "r", types.NewPointer(named))
+		sig := types.NewSignatureType(recv, nil, nil, newSig(param).Params(), nil, false)
+		named.AddMethod(types.NewFunc(0, pkg, "Do", sig))
+		return named
+	}
+
+	if !implementsInterface(newNamed("good", str), iface) {
+		t.Error("Expected type with matching method to implement the interface")
+	}
+	if implementsInterface(newNamed("bad", types.Typ[types.Int]), iface) {
+		t.Error("Expected type with different signature not to implement the interface")
+	}
+}
@@ -0,0 +1,351 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// jsonSchemaVersion is the version of the -format json schema. It changes
+// whenever fields are renamed or removed; new optional fields keep it.
+const jsonSchemaVersion = "1"
+
+// jsonReport is the -format json document. Field names are part of the
+// documented schema and must stay stable.
+type jsonReport struct {
+	SchemaVersion string      `json:"schema_version"`
+	Tool          jsonTool    `json:"tool"`
+	Directory     string      `json:"directory"`
+	Summary       jsonSummary `json:"summary"`
+
+	FunctionsWithoutTests []jsonFunction        `json:"functions_without_tests"`
+	MisplacedTests        []jsonMisplacedTest   `json:"misplaced_tests"`
+	LowCoverage           []jsonLowCoverageFunc `json:"low_coverage_functions"`
+
+	// Optional sections, omitted when empty
+	HeuristicallyTested        []jsonFunction            `json:"heuristically_tested_functions,omitempty"`
+	ReferencedOnly             []jsonFunction            `json:"referenced_only_functions,omitempty"`
+	WhiteBoxOnly               []jsonFunction            `json:"white_box_only_functions,omitempty"`
+	IndirectlyTested           []jsonReachedFunc         `json:"indirectly_tested_functions,omitempty"`
+	UnexercisedImpls           []jsonUnexercisedImpl     `json:"unexercised_implementations,omitempty"`
+	IntegrationOnly            []jsonFunction            `json:"integration_only_functions,omitempty"`
+	CoverageUnknown            []jsonFunction            `json:"coverage_unknown_functions,omitempty"`
+	FailedPackages             []jsonFailedPackage       `json:"failed_packages,omitempty"`
+	BarelyExercised            []jsonBarelyExercisedFunc `json:"barely_exercised_functions,omitempty"`
+	CrossPackage               []jsonCrossPackageFunc    `json:"cross_package_functions,omitempty"`
+	TagSets                    []string                  `json:"tag_sets,omitempty"`
+	TagSetCoverageDiffs        []jsonTagSetCoverage      `json:"tag_set_coverage_differences,omitempty"`
+	TestMatrix                 []jsonFuncTests           `json:"test_matrix,omitempty"`
+	IncidentallyCovered        []jsonFuncTests           `json:"incidentally_covered_functions,omitempty"`
+	TestsWithoutUniqueCoverage []jsonTestRef             `json:"tests_without_unique_coverage,omitempty"`
+}
+
+// jsonTool identifies the program that produced the report
+type jsonTool struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// jsonSummary holds the number of entries of every report section
+type jsonSummary struct {
+	Functions                  int `json:"functions"`
+	FunctionsWithoutTests      int `json:"functions_without_tests"`
+	MisplacedTests             int `json:"misplaced_tests"`
+	LowCoverageFunctions       int `json:"low_coverage_functions"`
+	HeuristicallyTested        int `json:"heuristically_tested_functions"`
+	ReferencedOnly             int `json:"referenced_only_functions"`
+	WhiteBoxOnly               int `json:"white_box_only_functions"`
+	IndirectlyTested           int `json:"indirectly_tested_functions"`
+	UnexercisedImpls           int `json:"unexercised_implementations"`
+	IntegrationOnly            int `json:"integration_only_functions"`
+	CoverageUnknown            int `json:"coverage_unknown_functions"`
+	FailedPackages             int `json:"failed_packages"`
+	BarelyExercised            int `json:"barely_exercised_functions"`
+	CrossPackage               int `json:"cross_package_functions"`
+	TagSetCoverageDiffs        int `json:"tag_set_coverage_differences"`
+	IncidentallyCovered        int `json:"incidentally_covered_functions"`
+	TestsWithoutUniqueCoverage int `json:"tests_without_unique_coverage"`
+}
+
+// jsonFunction is a function or method with its verdict
+type jsonFunction struct {
+	File      string `json:"file"`
+	Line      int    `json:"line"`
+	Name      string `json:"name"`
+	Receiver  string `json:"receiver,omitempty"`
+	Package   string `json:"package,omitempty"`
+	Verdict   string `json:"verdict"`
+	Reason    string `json:"reason,omitempty"`
+	TestStyle string `json:"test_style,omitempty"`
+}
+
+// jsonMisplacedTest is a test declared in another file than the function it tests
+type jsonMisplacedTest struct {
+	Test         string `json:"test"`
+	Line         int    `json:"line"`
+	ActualFile   string `json:"actual_file"`
+	ExpectedFile string `json:"expected_file"`
+}
+
+// jsonLowCoverageFunc is a function with coverage below the threshold
+type jsonLowCoverageFunc struct {
+	File      string          `json:"file"`
+	Line      int             `json:"line"`
+	Name      string          `json:"name"`
+	Receiver  string          `json:"receiver,omitempty"`
+	Coverage  float64         `json:"coverage"`
+	Threshold float64         `json:"threshold"`
+	MinHits   int             `json:"min_hits"`
+	MaxHits   int             `json:"max_hits"`
+	Uncovered []jsonLineRange `json:"uncovered"`
+
+	UncoveredSource []jsonSourceLine `json:"uncovered_source,omitempty"` // only with -show-uncovered
+}
+
+// jsonLineRange is an inclusive range of source lines
+type jsonLineRange struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// jsonSourceLine is a numbered line of a source file
+type jsonSourceLine struct {
+	Line int    `json:"line"`
+	Text string `json:"text"`
+}
+
+// jsonReachedFunc is a function reached from tests only through other functions
+type jsonReachedFunc struct {
+	jsonFunction
+	Chain []string `json:"chain"`
+}
+
+// jsonUnexercisedImpl is an interface method implementation never exercised by tests
+type jsonUnexercisedImpl struct {
+	jsonFunction
+	Interface string `json:"interface"`
+}
+
+// jsonFailedPackage is a package whose tests failed or did not build
+type jsonFailedPackage struct {
+	Package     string   `json:"package"`
+	Dir         string   `json:"dir,omitempty"`
+	Tests       []string `json:"failed_tests"`
+	BuildFailed bool     `json:"build_failed"`
+}
+
+// jsonBarelyExercisedFunc is a covered function none of whose blocks ran more than -max-hits times
+type jsonBarelyExercisedFunc struct {
+	File     string  `json:"file"`
+	Line     int     `json:"line"`
+	Name     string  `json:"name"`
+	Receiver string  `json:"receiver,omitempty"`
+	Coverage float64 `json:"coverage"`
+	MinHits  int     `json:"min_hits"`
+	MaxHits  int     `json:"max_hits"`
+}
+
+// jsonCrossPackageFunc is a function executed by the tests of other packages
+type jsonCrossPackageFunc struct {
+	File     string   `json:"file"`
+	Line     int      `json:"line"`
+	Name     string   `json:"name"`
+	Receiver string   `json:"receiver,omitempty"`
+	Coverage float64  `json:"coverage"`
+	Packages []string `json:"covered_by_packages"`
+}
+
+// jsonTagSetCoverage is a function whose coverage differs between build tag sets
+type jsonTagSetCoverage struct {
+	File     string             `json:"file"`
+	Line     int                `json:"line"`
+	Name     string             `json:"name"`
+	Receiver string             `json:"receiver,omitempty"`
+	Coverage map[string]float64 `json:"coverage_by_tag_set"`
+}
+
+// jsonFuncTests is a function with the tests whose isolated run covered it
+type jsonFuncTests struct {
+	jsonFunction
+	Tests []jsonTestRef `json:"tests"`
+}
+
+// jsonTestRef identifies a test, or a package's tests for per-package matrices
+type jsonTestRef struct {
+	Name    string `json:"name"`
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Package string `json:"package"`
+}
+
+// writeJSONReport writes the analysis result as an indented -format json document
+func writeJSONReport(w io.Writer, result *AnalysisResult, baseDir string) error {
+	report := jsonReport{
+		SchemaVersion: jsonSchemaVersion,
+		Tool:          jsonTool{Name: "testvet", Version: toolVersion()},
+		Directory:     baseDir,
+		Summary: jsonSummary{
+			Functions:                  len(result.Functions),
+			FunctionsWithoutTests:      len(result.FunctionsWithoutTests),
+			MisplacedTests:             len(result.MisplacedTests),
+			LowCoverageFunctions:       len(result.LowCoverageFuncs),
+			HeuristicallyTested:        len(result.HeuristicallyTestedFuncs),
+			ReferencedOnly:             len(result.ReferencedOnlyFuncs),
+			WhiteBoxOnly:               len(result.WhiteBoxOnlyFuncs),
+			IndirectlyTested:           len(result.IndirectlyTestedFuncs),
+			UnexercisedImpls:           len(result.UnexercisedImpls),
+			IntegrationOnly:            len(result.IntegrationOnlyFuncs),
+			CoverageUnknown:            len(result.CoverageUnknownFuncs),
+			FailedPackages:             len(result.FailedPackages),
+			BarelyExercised:            len(result.BarelyExercisedFuncs),
+			CrossPackage:               len(result.CrossPackageFuncs),
+			TagSetCoverageDiffs:        len(result.TagSetCoverageDiffs),
+			IncidentallyCovered:        len(result.IncidentallyCoveredFuncs),
+			TestsWithoutUniqueCoverage: len(result.RedundantTests),
+		},
+
+		// Required sections are empty arrays rather than null
+		FunctionsWithoutTests: jsonFunctions(result.FunctionsWithoutTests),
+		MisplacedTests:        []jsonMisplacedTest{},
+		LowCoverage:           []jsonLowCoverageFunc{},
+
+		HeuristicallyTested: jsonFunctions(result.HeuristicallyTestedFuncs),
+		ReferencedOnly:      jsonFunctions(result.ReferencedOnlyFuncs),
+		WhiteBoxOnly:        jsonFunctions(result.WhiteBoxOnlyFuncs),
+		IntegrationOnly:     jsonFunctions(result.IntegrationOnlyFuncs),
+		CoverageUnknown:     jsonFunctions(result.CoverageUnknownFuncs),
+		TagSets:             result.TagSets,
+		TestMatrix:          jsonFuncTestsList(result.TestMatrix),
+		IncidentallyCovered: jsonFuncTestsList(result.IncidentallyCoveredFuncs),
+	}
+
+	for _, mt := range result.MisplacedTests {
+		report.MisplacedTests = append(report.MisplacedTests, jsonMisplacedTest{
+			Test:         mt.Test.Name,
+			Line:         mt.Test.Line,
+			ActualFile:   mt.ActualFile,
+			ExpectedFile: mt.ExpectedFile,
+		})
+	}
+	for _, f := range result.LowCoverageFuncs {
+		uncovered := []jsonLineRange{}
+		for _, r := range f.Uncovered {
+			uncovered = append(uncovered, jsonLineRange{Start: r.Start, End: r.End})
+		}
+		var source []jsonSourceLine
+		for _, l := range f.UncoveredSource {
+			source = append(source, jsonSourceLine{Line: l.Line, Text: l.Text})
+		}
+		report.LowCoverage = append(report.LowCoverage, jsonLowCoverageFunc{
+			File:            f.File,
+			Line:            f.Line,
+			Name:            f.Name,
+			Receiver:        f.Receiver,
+			Coverage:        f.Coverage,
+			Threshold:       f.Threshold,
+			MinHits:         f.MinHits,
+			MaxHits:         f.MaxHits,
+			Uncovered:       uncovered,
+			UncoveredSource: source,
+		})
+	}
+	for _, r := range result.IndirectlyTestedFuncs {
+		report.IndirectlyTested = append(report.IndirectlyTested, jsonReachedFunc{jsonFunction: newJSONFunction(r.Func), Chain: r.Chain})
+	}
+	for _, u := range result.UnexercisedImpls {
+		report.UnexercisedImpls = append(report.UnexercisedImpls, jsonUnexercisedImpl{jsonFunction: newJSONFunction(u.Func), Interface: u.Interface})
+	}
+	for _, pkg := range result.FailedPackages {
+		tests := pkg.Tests
+		if tests == nil {
+			tests = []string{}
+		}
+		report.FailedPackages = append(report.FailedPackages, jsonFailedPackage{
+			Package:     pkg.Package,
+			Dir:         pkg.Dir,
+			Tests:       tests,
+			BuildFailed: pkg.BuildFailed,
+		})
+	}
+	for _, f := range result.BarelyExercisedFuncs {
+		report.BarelyExercised = append(report.BarelyExercised, jsonBarelyExercisedFunc{
+			File:     f.File,
+			Line:     f.Line,
+			Name:     f.Name,
+			Receiver: f.Receiver,
+			Coverage: f.Coverage,
+			MinHits:  f.MinHits,
+			MaxHits:  f.MaxHits,
+		})
+	}
+	for _, f := range result.CrossPackageFuncs {
+		report.CrossPackage = append(report.CrossPackage, jsonCrossPackageFunc{
+			File:     f.File,
+			Line:     f.Line,
+			Name:     f.Name,
+			Receiver: f.Receiver,
+			Coverage: f.Coverage,
+			Packages: f.Packages,
+		})
+	}
+	for _, f := range result.TagSetCoverageDiffs {
+		byTagSet := make(map[string]float64)
+		for i, cov := range f.Coverage {
+			byTagSet[result.TagSets[i]] = cov
+		}
+		report.TagSetCoverageDiffs = append(report.TagSetCoverageDiffs, jsonTagSetCoverage{
+			File:     f.File,
+			Line:     f.Line,
+			Name:     f.Name,
+			Receiver: f.Receiver,
+			Coverage: byTagSet,
+		})
+	}
+	for _, t := range result.RedundantTests {
+		report.TestsWithoutUniqueCoverage = append(report.TestsWithoutUniqueCoverage, newJSONTestRef(t))
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
+
+// newJSONFunction converts a function to its JSON representation
+func newJSONFunction(f FuncInfo) jsonFunction {
+	return jsonFunction{
+		File:      f.File,
+		Line:      f.Line,
+		Name:      f.Name,
+		Receiver:  f.Receiver,
+		Package:   f.Package,
+		Verdict:   string(f.Verdict),
+		Reason:    f.Reason,
+		TestStyle: string(f.TestStyle),
+	}
+}
+
+// jsonFunctions converts functions to their JSON representation, never returning nil
+func jsonFunctions(funcs []FuncInfo) []jsonFunction {
+	result := make([]jsonFunction, 0, len(funcs))
+	for _, f := range funcs {
+		result = append(result, newJSONFunction(f))
+	}
+	return result
+}
+
+// newJSONTestRef converts a test reference to its JSON representation
+func newJSONTestRef(t TestRef) jsonTestRef {
+	return jsonTestRef{Name: t.Name, File: t.File, Line: t.Line, Package: t.Package}
+}
+
+// jsonFuncTestsList converts functions with their tests to their JSON representation
+func jsonFuncTestsList(funcs []FuncTests) []jsonFuncTests {
+	var result []jsonFuncTests
+	for _, ft := range funcs {
+		tests := make([]jsonTestRef, len(ft.Tests))
+		for i, t := range ft.Tests {
+			tests[i] = newJSONTestRef(t)
+		}
+		result = append(result, jsonFuncTests{jsonFunction: newJSONFunction(ft.Func), Tests: tests})
+	}
+	return result
+}
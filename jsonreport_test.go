@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestWriteJSONReport(t *testing.T) {
+	parse := FuncInfo{Name: "Parse", File: "parse.go", Line: 3, Package: "example.com/p", Verdict: VerdictUntested}
+	tests := []struct {
+		name   string
+		result *AnalysisResult
+		want   string
+	}{
+		{
+			name:   "empty result",
+			result: &AnalysisResult{},
+			want: `{
+  "schema_version": "1",
+  "tool": {"name": "testvet", "version": "v1.2.3"},
+  "directory": "/src/p",
+  "summary": {
+    "functions": 0, "functions_without_tests": 0, "misplaced_tests": 0, "low_coverage_functions": 0,
+    "heuristically_tested_functions": 0, "referenced_only_functions": 0, "white_box_only_functions": 0,
+    "indirectly_tested_functions": 0, "unexercised_implementations": 0, "integration_only_functions": 0,
+    "coverage_unknown_functions": 0, "failed_packages": 0, "barely_exercised_functions": 0,
+    "cross_package_functions": 0, "tag_set_coverage_differences": 0, "incidentally_covered_functions": 0,
+    "tests_without_unique_coverage": 0
+  },
+  "functions_without_tests": [],
+  "misplaced_tests": [],
+  "low_coverage_functions": []
+}`,
+		},
+		{
+			name: "sections",
+			result: &AnalysisResult{
+				Functions:             []FuncInfo{parse},
+				FunctionsWithoutTests: []FuncInfo{parse},
+				MisplacedTests: []MisplacedTest{
+					{Test: TestInfo{Name: "TestParse", Line: 7}, ActualFile: "util_test.go", ExpectedFile: "parse_test.go"},
+				},
+				LowCoverageFuncs: []LowCoverageFunc{
+					{File: "run.go", Line: 10, Name: "Run", Receiver: "Server", Coverage: 25, Threshold: 80, MaxHits: 3, Uncovered: []LineRange{{12, 14}}},
+				},
+				FailedPackages: []FailedPackage{{Package: "example.com/p/broken", Dir: "broken", BuildFailed: true}},
+				TagSets:        []string{"default", "integration"},
+				TagSetCoverageDiffs: []TagSetCoverage{
+					{File: "db.go", Line: 5, Name: "Open", Coverage: []float64{0, 100}},
+				},
+			},
+			want: `{
+  "schema_version": "1",
+  "tool": {"name": "testvet", "version": "v1.2.3"},
+  "directory": "/src/p",
+  "summary": {
+    "functions": 1, "functions_without_tests": 1, "misplaced_tests": 1, "low_coverage_functions": 1,
+    "heuristically_tested_functions": 0, "referenced_only_functions": 0, "white_box_only_functions": 0,
+    "indirectly_tested_functions": 0, "unexercised_implementations": 0, "integration_only_functions": 0,
+    "coverage_unknown_functions": 0, "failed_packages": 1, "barely_exercised_functions": 0,
+    "cross_package_functions": 0, "tag_set_coverage_differences": 1, "incidentally_covered_functions": 0,
+    "tests_without_unique_coverage": 0
+  },
+  "functions_without_tests": [
+    {"file": "parse.go", "line": 3, "name": "Parse", "package": "example.com/p", "verdict": "untested"}
+  ],
+  "misplaced_tests": [
+    {"test": "TestParse", "line": 7, "actual_file": "util_test.go", "expected_file": "parse_test.go"}
+  ],
+  "low_coverage_functions": [
+    {
+      "file": "run.go", "line": 10, "name": "Run", "receiver": "Server", "coverage": 25, "threshold": 80,
+      "min_hits": 0, "max_hits": 3, "uncovered": [{"start": 12, "end": 14}]
+    }
+  ],
+  "failed_packages": [
+    {"package": "example.com/p/broken", "dir": "broken", "failed_tests": [], "build_failed": true}
+  ],
+  "tag_sets": ["default", "integration"],
+  "tag_set_coverage_differences": [
+    {"file": "db.go", "line": 5, "name": "Open", "coverage_by_tag_set": {"default": 0, "integration": 100}}
+  ]
+}`,
+		},
+	}
+
+	defer func(v string) { version = v }(version)
+	version = "v1.2.3"
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := writeJSONReport(&buf, tt.result, "/src/p"); err != nil {
+				t.Fatalf("writeJSONReport failed: %v", err)
+			}
+
+			var got, want any
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("invalid JSON output: %v\n%s", err, buf.String())
+			}
+			if err := json.Unmarshal([]byte(tt.want), &want); err != nil {
+				t.Fatalf("invalid expected JSON: %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("writeJSONReport() =\n%s\nwant\n%s", buf.String(), tt.want)
+			}
+		})
+	}
+}
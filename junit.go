@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+)
+
+// junitTestSuites is the root of a JUnit XML report
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+// junitTestSuite holds the functions and tests of a package
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+// junitTestCase is an analyzed function or test placement
+type junitTestCase struct {
+	Name      string         `xml:"name,attr"`
+	ClassName string         `xml:"classname,attr"`
+	File      string         `xml:"file,attr"`
+	Line      int            `xml:"line,attr"`
+	Failures  []junitFailure `xml:"failure"`
+}
+
+// junitFailure describes a finding of a test case
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// writeJUnitReport writes the analysis result as a JUnit XML report with a
+// test suite per package. Every analyzed function is a test case failing when
+// it has no tests or its coverage is below the threshold, and every test is a
+// test case failing when it is misplaced. A test case has a failure per finding.
+func writeJUnitReport(w io.Writer, result *AnalysisResult) error {
+	// Name the suites after the import paths of the packages when known
+	pkgNames := make(map[string]string)
+	for _, f := range result.Functions {
+		if f.Package != "" {
+			pkgNames[filepath.Dir(f.File)] = f.Package
+		}
+	}
+
+	suites := make(map[string]*junitTestSuite)
+	var cases []junitTestCase
+	byPosition := make(map[funcPosition]int)
+	addCase := func(name, file string, line int) int {
+		dir := filepath.Dir(file)
+		suite, ok := pkgNames[dir]
+		if !ok {
+			suite = filepath.ToSlash(dir)
+		}
+		if suites[suite] == nil {
+			suites[suite] = &junitTestSuite{Name: suite}
+		}
+		cases = append(cases, junitTestCase{Name: name, ClassName: suite, File: filepath.ToSlash(file), Line: line})
+		return len(cases) - 1
+	}
+
+	for _, f := range result.Functions {
+		byPosition[funcPosition{File: f.File, Line: f.Line}] = addCase(funcDescription(f.Receiver, f.Name), f.File, f.Line)
+	}
+	testCases := make(map[funcPosition]int)
+	for _, test := range result.Tests {
+		testCases[funcPosition{File: test.File, Line: test.Line}] = addCase(test.Name, test.File, test.Line)
+	}
+
+	for _, f := range collectFindings(result) {
+		positions := byPosition
+		if f.Kind == findingMisplaced {
+			positions = testCases
+		}
+		i, ok := positions[funcPosition{File: f.File, Line: f.Line}]
+		if !ok {
+			// e.g. low coverage functions excluded from the analysis
+			i = addCase(f.Subject, f.File, f.Line)
+			positions[funcPosition{File: f.File, Line: f.Line}] = i
+		}
+		cases[i].Failures = append(cases[i].Failures, junitFailure{Message: f.Message, Type: f.Kind, Text: f.Message})
+	}
+
+	report := junitTestSuites{Name: "testvet"}
+	for _, c := range cases {
+		suite := suites[c.ClassName]
+		suite.Cases = append(suite.Cases, c)
+		suite.Tests++
+		report.Tests++
+		if len(c.Failures) > 0 {
+			suite.Failures++
+			report.Failures++
+		}
+	}
+	for _, suite := range suites {
+		sort.SliceStable(suite.Cases, func(i, j int) bool {
+			if suite.Cases[i].File != suite.Cases[j].File {
+				return suite.Cases[i].File < suite.Cases[j].File
+			}
+			return suite.Cases[i].Line < suite.Cases[j].Line
+		})
+		report.Suites = append(report.Suites, *suite)
+	}
+	sort.Slice(report.Suites, func(i, j int) bool {
+		return report.Suites[i].Name < report.Suites[j].Name
+	})
+
+	out, err := xml.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s%s\n", xml.Header, out)
+	return err
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWriteJUnitReport(t *testing.T) {
+	result := &AnalysisResult{
+		Functions: []FuncInfo{
+			{Name: "Parse", File: "parse.go", Line: 3, Package: "example.com/p"},
+			{Name: "Start", Receiver: "Server", File: "server.go", Line: 12, Package: "example.com/p"},
+			{Name: "Open", File: "db/db.go", Line: 5},
+		},
+		Tests: []TestInfo{
+			{Name: "TestOpen", File: "db/db_test.go", Line: 8},
+			{Name: "TestParse", File: "util_test.go", Line: 7},
+		},
+		FunctionsWithoutTests: []FuncInfo{
+			{Name: "Start", Receiver: "Server", File: "server.go", Line: 12, Package: "example.com/p"},
+		},
+		MisplacedTests: []MisplacedTest{
+			{Test: TestInfo{Name: "TestParse", Line: 7}, ActualFile: "util_test.go", ExpectedFile: "parse_test.go"},
+		},
+		LowCoverageFuncs: []LowCoverageFunc{
+			{File: "server.go", Line: 12, Name: "Start", Receiver: "Server", Coverage: 0, Threshold: 80},
+			{File: "db/db.go", Line: 20, Name: "migrate", Coverage: 50, Threshold: 80},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := writeJUnitReport(&buf, result); err != nil {
+		t.Fatalf("writeJUnitReport failed: %v", err)
+	}
+	if !strings.HasPrefix(buf.String(), xml.Header) {
+		t.Errorf("Report does not start with the XML header:\n%s", buf.String())
+	}
+	var report junitTestSuites
+	if err := xml.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatalf("invalid JUnit output: %v\n%s", err, buf.String())
+	}
+
+	if report.Tests != 6 || report.Failures != 3 {
+		t.Errorf("testsuites tests = %d, failures = %d, want 6 and 3", report.Tests, report.Failures)
+	}
+
+	type testCase struct {
+		suite, name, file string
+		line              int
+		failureTypes      []string
+		failures          []string
+	}
+	var got []testCase
+	for _, suite := range report.Suites {
+		failures := 0
+		for _, c := range suite.Cases {
+			tc := testCase{suite: suite.Name, name: c.Name, file: c.File, line: c.Line}
+			if len(c.Failures) > 0 {
+				failures++
+			}
+			for _, failure := range c.Failures {
+				tc.failureTypes = append(tc.failureTypes, failure.Type)
+				tc.failures = append(tc.failures, failure.Text)
+			}
+			got = append(got, tc)
+		}
+		if suite.Tests != len(suite.Cases) || suite.Failures != failures {
+			t.Errorf("suite %s: tests = %d, failures = %d, want %d and %d", suite.Name, suite.Tests, suite.Failures, len(suite.Cases), failures)
+		}
+	}
+	want := []testCase{
+		{"db", "Open", "db/db.go", 5, nil, nil},
+		{"db", "migrate", "db/db.go", 20, []string{findingLowCoverage}, []string{"migrate has 50.0% statement coverage, below 80.0%"}},
+		{"db", "TestOpen", "db/db_test.go", 8, nil, nil},
+		{"example.com/p", "Parse", "parse.go", 3, nil, nil},
+		{"example.com/p", "(Server).Start", "server.go", 12, []string{findingUntested, findingLowCoverage},
+			[]string{"(Server).Start is not called from any test", "(Server).Start has 0.0% statement coverage, below 80.0%"}},
+		{"example.com/p", "TestParse", "util_test.go", 7, []string{findingMisplaced}, []string{"TestParse belongs in parse_test.go"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("test cases =\n%v\nwant\n%v", got, want)
+	}
+}
@@ -0,0 +1,466 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+// loadMode is the set of package information needed for type-checked analysis
+const loadMode = packages.NeedName | packages.NeedFiles | packages.NeedSyntax |
+	packages.NeedTypes | packages.NeedTypesInfo | packages.NeedModule
+
+// typeContext carries the type information of the package a file belongs to
+type typeContext struct {
+	info       *types.Info
+	pkgPath    string
+	modulePkgs map[string]bool // import paths of all packages loaded from the module
+
+	// ifaceMethods collects the interface methods called from test files, keyed by qualified key
+	ifaceMethods map[string]*types.Func
+}
+
+// loadTypedPackages loads all packages under dir, including their test variants,
+// with full type information. It returns an error if nothing could be type-checked
+// (e.g. the directory is not part of a module), so callers can fall back to
+// syntax-only analysis. Errors of individual packages are reported as warnings,
+// since their functions may be analyzed with incomplete type information.
+func loadTypedPackages(dir string) ([]*packages.Package, error) {
+	cfg := &packages.Config{
+		Mode:  loadMode,
+		Dir:   dir,
+		Tests: true,
+	}
+	pkgs, err := packages.Load(cfg, "./...")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load packages: %w", err)
+	}
+
+	typed := false
+	var pkgErrors []string
+	for _, pkg := range pkgs {
+		for _, e := range pkg.Errors {
+			pkgErrors = append(pkgErrors, e.Error())
+		}
+		if pkg.TypesInfo != nil && len(pkg.Syntax) > 0 {
+			typed = true
+		}
+	}
+	if !typed {
+		return nil, fmt.Errorf("no type-checked packages found in %s", dir)
+	}
+
+	// Test variants of a package repeat its errors
+	sort.Strings(pkgErrors)
+	for _, e := range slices.Compact(pkgErrors) {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", e)
+	}
+
+	return pkgs, nil
+}
+
+// parseTypedPackages extracts functions and tests from type-checked packages.
+// Files appearing in several package variants (e.g. "p" and "p [p.test]") are
+// processed only once. Files left out of the load (e.g. by build constraints
+// for another GOOS) are parsed without type information.
+func parseTypedPackages(dir string, pkgs []*packages.Package, excludePrivate, verbose bool) (*parseResult, error) {
+	result := newParseResult()
+	result.typed = true
+	result.pkgs = pkgs
+	result.ifaceMethods = make(map[string]*types.Func)
+	result.concreteTypes = make(map[string]*types.Named)
+
+	modulePkgs := make(map[string]bool)
+	for _, pkg := range pkgs {
+		modulePkgs[pkg.PkgPath] = true
+		// Only the plain package variant shares type identities with its importers
+		if pkg.ID == pkg.PkgPath && pkg.Types != nil {
+			collectConcreteTypes(pkg.Types, result.concreteTypes)
+		}
+	}
+
+	seen := make(map[string]bool)
+	loaded := make(map[string]bool) // relative paths of the files processed with type information
+	for _, pkg := range pkgs {
+		if pkg.TypesInfo == nil {
+			continue
+		}
+		tc := &typeContext{
+			info:         pkg.TypesInfo,
+			pkgPath:      pkg.PkgPath,
+			modulePkgs:   modulePkgs,
+			ifaceMethods: result.ifaceMethods,
+		}
+
+		for _, file := range pkg.Syntax {
+			path := pkg.Fset.Position(file.Pos()).Filename
+			if seen[path] {
+				continue
+			}
+			seen[path] = true
+
+			// Skip generated files (such as the test main) that live outside dir
+			relPath, err := filepath.Rel(dir, path)
+			if err != nil || strings.HasPrefix(relPath, "..") {
+				continue
+			}
+			loaded[relPath] = true
+
+			isTestFile := strings.HasSuffix(path, "_test.go")
+			processFileDeclarations(file, pkg.Fset, relPath, isTestFile, excludePrivate, result, tc)
+		}
+	}
+
+	fset := token.NewFileSet()
+	var untyped []string
+	err := walkGoFiles(dir, func(path, relPath string) error {
+		if loaded[relPath] {
+			return nil
+		}
+		file, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+		if err != nil {
+			if verbose {
+				fmt.Fprintf(os.Stderr, "Warning: could not parse %s: %v\n", relPath, err)
+			}
+			return nil
+		}
+		untyped = append(untyped, relPath)
+		isTestFile := strings.HasSuffix(path, "_test.go")
+		processFileDeclarations(file, fset, relPath, isTestFile, excludePrivate, result, nil)
+		for i := range result.fileTests[relPath] {
+			result.fileTests[relPath][i].Untyped = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(untyped) > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: %d files excluded from the package load (e.g. by build constraints) were analyzed without type information\n", len(untyped))
+		if verbose {
+			for _, relPath := range untyped {
+				fmt.Fprintf(os.Stderr, "  %s\n", relPath)
+			}
+		}
+		result.helpers = dropForeignAliases(result.helpers, result.fileFunctions)
+	}
+
+	propagateHelperCalls(result.fileTests, result.helpers)
+	return result, nil
+}
+
+// resolveCalls walks the body of a function and resolves each call to the
+// function object it invokes. Called module functions are recorded in test by
+// local name (in Type_Method form for methods) and fully qualified key, calls
+// through interfaces by interface method key. Module types used in the body are
+// recorded as concrete types. Calls into packages outside the module are ignored.
+func (tc *typeContext) resolveCalls(funcDecl *ast.FuncDecl, test *TestInfo) {
+	if funcDecl.Body == nil {
+		return
+	}
+
+	seen := make(map[string]bool)
+	calleeIdents := make(map[*ast.Ident]bool)
+	assigned := make(map[ast.Expr]bool)
+	ast.Inspect(funcDecl.Body, func(n ast.Node) bool {
+		// Overwriting a function variable (e.g. stubbing it) is not a use of it
+		if assign, ok := n.(*ast.AssignStmt); ok {
+			for _, lhs := range assign.Lhs {
+				assigned[ast.Unparen(lhs)] = true
+			}
+		}
+		expr, ok := n.(ast.Expr)
+		if !ok {
+			return true
+		}
+		switch node := expr.(type) {
+		case *ast.CallExpr:
+			// Calls are visited before their children, so the callee
+			// identifier is known by the time it is reached
+			if ident := tc.calleeIdent(node); ident != nil {
+				calleeIdents[ident] = true
+			}
+			tc.recordCall(node, test, seen)
+		case *ast.SelectorExpr:
+			if !calleeIdents[node.Sel] && !assigned[node] {
+				tc.recordVarReference(node, test, seen)
+			}
+		case *ast.Ident:
+			if !calleeIdents[node] && !assigned[node] {
+				tc.recordReference(node, test, seen)
+				tc.recordVarReference(node, test, seen)
+			}
+		}
+		tc.recordConcreteType(expr, test, seen)
+		return true
+	})
+}
+
+// recordReference records a module function or method used as a value
+// (e.g. passed as a callback or stored in a struct field) without being called
+func (tc *typeContext) recordReference(ident *ast.Ident, test *TestInfo, seen map[string]bool) {
+	fn, ok := tc.info.Uses[ident].(*types.Func)
+	if !ok || fn.Pkg() == nil || !tc.modulePkgs[fn.Pkg().Path()] || isInterfaceMethod(fn) {
+		return
+	}
+
+	key := funcObjectKey(fn)
+	if seen["ref:"+key] {
+		return
+	}
+	seen["ref:"+key] = true
+
+	name := fn.Name()
+	if recv := funcRecvTypeName(fn); recv != "" {
+		name = recv + "_" + name
+	}
+	test.ReferencedFuncs = append(test.ReferencedFuncs, name)
+	test.ReferencedKeys = append(test.ReferencedKeys, key)
+}
+
+// recordVarReference records a package-level module variable, or a field of
+// one, used as a value. Variables not holding function literals match no function.
+func (tc *typeContext) recordVarReference(expr ast.Expr, test *TestInfo, seen map[string]bool) {
+	name, key := tc.funcVarUnit(expr)
+	if key == "" || seen["ref:"+key] {
+		return
+	}
+	seen["ref:"+key] = true
+	test.ReferencedFuncs = append(test.ReferencedFuncs, strings.Replace(name, ".", "_", 1))
+	test.ReferencedKeys = append(test.ReferencedKeys, key)
+}
+
+// funcVarUnit returns the unit name and qualified key of the package-level
+// module variable named by expr (parseHeader or pkg.ParseHeader), or of a field
+// of one (DefaultServer.Handler). It returns empty strings for other expressions.
+func (tc *typeContext) funcVarUnit(expr ast.Expr) (string, string) {
+	var ident *ast.Ident
+	switch e := ast.Unparen(expr).(type) {
+	case *ast.Ident:
+		ident = e
+	case *ast.SelectorExpr:
+		if field, ok := tc.info.Uses[e.Sel].(*types.Var); ok && field.IsField() {
+			name, key := tc.funcVarUnit(e.X)
+			if key == "" {
+				return "", ""
+			}
+			return name + "." + field.Name(), key + "." + field.Name()
+		}
+		ident = e.Sel
+	default:
+		return "", ""
+	}
+
+	v, ok := tc.info.Uses[ident].(*types.Var)
+	if !ok || v.IsField() || v.Pkg() == nil || v.Parent() != v.Pkg().Scope() || !tc.modulePkgs[v.Pkg().Path()] {
+		return "", ""
+	}
+	return v.Name(), qualifiedFuncKey(v.Pkg().Path(), "", v.Name())
+}
+
+// calleeIdent returns the identifier naming the function invoked by a call
+// (the method name for selector calls), or nil for other callee expressions
+func (tc *typeContext) calleeIdent(call *ast.CallExpr) *ast.Ident {
+	return funcIdent(tc.unwrapInstance(ast.Unparen(call.Fun)))
+}
+
+// funcIdent returns the identifier of a function expression (the selected
+// name for selectors), or nil for other expressions
+func funcIdent(expr ast.Expr) *ast.Ident {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		return e
+	case *ast.SelectorExpr:
+		return e.Sel
+	}
+	return nil
+}
+
+// unwrapInstance returns the generic function of an instantiation with
+// explicit type arguments (Map for Map[int, string]), or expr itself for other
+// expressions, including index expressions such as handlers[name]
+func (tc *typeContext) unwrapInstance(expr ast.Expr) ast.Expr {
+	unwrapped := unwrapTypeArgs(expr)
+	if unwrapped == expr {
+		return expr
+	}
+	if ident := funcIdent(ast.Unparen(unwrapped)); ident != nil {
+		if _, ok := tc.info.Instances[ident]; ok {
+			return unwrapped
+		}
+	}
+	return expr
+}
+
+// recordCall records the function or interface method invoked by a call
+func (tc *typeContext) recordCall(call *ast.CallExpr, test *TestInfo, seen map[string]bool) {
+	callee := typeutil.Callee(tc.info, call)
+	if _, ok := callee.(*types.Var); ok {
+		tc.recordVarCall(call, test, seen)
+		return
+	}
+	if fn, ok := callee.(*types.Func); ok {
+		tc.recordFunc(fn, test, seen)
+	}
+}
+
+// resolveAlias records the function of the package under test named by the
+// value of a variable aliasing it (e.g. parseHeader in var ParseHeader =
+// parseHeader) as called. Values naming anything else are ignored.
+func (tc *typeContext) resolveAlias(value ast.Expr, test *TestInfo) {
+	ident := funcIdent(tc.unwrapInstance(ast.Unparen(value)))
+	if ident == nil {
+		return
+	}
+	fn, ok := tc.info.Uses[ident].(*types.Func)
+	if !ok || isInterfaceMethod(fn) || fn.Pkg() == nil || fn.Pkg().Path() != strings.TrimSuffix(tc.pkgPath, "_test") {
+		return
+	}
+	tc.recordFunc(fn, test, make(map[string]bool))
+}
+
+// recordFunc records a called function or interface method
+func (tc *typeContext) recordFunc(fn *types.Func, test *TestInfo, seen map[string]bool) {
+	if fn.Pkg() == nil {
+		return
+	}
+
+	if isInterfaceMethod(fn) {
+		// Methods of anonymous interfaces cannot be keyed
+		if funcRecvTypeName(fn) == "" {
+			return
+		}
+		key := funcObjectKey(fn)
+		if !seen["iface:"+key] {
+			seen["iface:"+key] = true
+			test.InterfaceCalls = append(test.InterfaceCalls, key)
+			tc.ifaceMethods[key] = fn
+		}
+		return
+	}
+
+	if !tc.modulePkgs[fn.Pkg().Path()] {
+		return
+	}
+	key := funcObjectKey(fn)
+	if seen["func:"+key] {
+		return
+	}
+	seen["func:"+key] = true
+
+	name := fn.Name()
+	if recv := funcRecvTypeName(fn); recv != "" {
+		name = recv + "_" + name
+	}
+	test.CalledFuncs = append(test.CalledFuncs, name)
+	test.CalledKeys = append(test.CalledKeys, key)
+}
+
+// recordVarCall records a call through a package-level module function
+// variable or a field of one, e.g. parseHeader(h) or DefaultServer.Handler(w, r)
+func (tc *typeContext) recordVarCall(call *ast.CallExpr, test *TestInfo, seen map[string]bool) {
+	name, key := tc.funcVarUnit(call.Fun)
+	if key == "" || seen["func:"+key] {
+		return
+	}
+	seen["func:"+key] = true
+	test.CalledFuncs = append(test.CalledFuncs, strings.Replace(name, ".", "_", 1))
+	test.CalledKeys = append(test.CalledKeys, key)
+}
+
+// recordConcreteType records the module type of an expression, if it is a
+// named non-interface type (or a pointer to one)
+func (tc *typeContext) recordConcreteType(expr ast.Expr, test *TestInfo, seen map[string]bool) {
+	t := tc.info.TypeOf(expr)
+	if t == nil {
+		return
+	}
+	t = types.Unalias(t)
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = types.Unalias(ptr.Elem())
+	}
+	named, ok := t.(*types.Named)
+	if !ok || types.IsInterface(named) {
+		return
+	}
+	obj := named.Obj()
+	if obj.Pkg() == nil || !tc.modulePkgs[obj.Pkg().Path()] {
+		return
+	}
+
+	key := typeKey(obj)
+	if !seen["type:"+key] {
+		seen["type:"+key] = true
+		test.ConcreteTypes = append(test.ConcreteTypes, key)
+	}
+}
+
+// collectConcreteTypes adds the non-generic, non-interface named types declared
+// at package level in pkg to types, keyed by qualified type name
+func collectConcreteTypes(pkg *types.Package, concreteTypes map[string]*types.Named) {
+	scope := pkg.Scope()
+	for _, name := range scope.Names() {
+		obj, ok := scope.Lookup(name).(*types.TypeName)
+		if !ok || obj.IsAlias() {
+			continue
+		}
+		named, ok := obj.Type().(*types.Named)
+		if !ok || types.IsInterface(named) || named.TypeParams().Len() > 0 {
+			continue
+		}
+		concreteTypes[typeKey(obj)] = named
+	}
+}
+
+// isInterfaceMethod reports whether fn is an abstract interface method
+func isInterfaceMethod(fn *types.Func) bool {
+	recv := fn.Signature().Recv()
+	return recv != nil && types.IsInterface(recv.Type())
+}
+
+// typeKey returns the qualified name of a named type, e.g. "example.com/pkg.Store"
+func typeKey(obj *types.TypeName) string {
+	return obj.Pkg().Path() + "." + obj.Name()
+}
+
+// funcObjectKey returns the fully qualified key of a function object,
+// e.g. "example.com/pkg.Func" or "(example.com/pkg.Type).Method".
+// Instantiated generic functions and methods map to their generic origin.
+func funcObjectKey(fn *types.Func) string {
+	fn = fn.Origin()
+	return qualifiedFuncKey(fn.Pkg().Path(), funcRecvTypeName(fn), fn.Name())
+}
+
+// funcRecvTypeName returns the receiver type name of a method, or "" for functions
+func funcRecvTypeName(fn *types.Func) string {
+	recv := fn.Signature().Recv()
+	if recv == nil {
+		return ""
+	}
+	t := types.Unalias(recv.Type())
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = types.Unalias(ptr.Elem())
+	}
+	if named, ok := t.(*types.Named); ok {
+		return named.Obj().Name()
+	}
+	return ""
+}
+
+// qualifiedFuncKey builds the fully qualified key for a function in a package
+func qualifiedFuncKey(pkgPath, receiver, name string) string {
+	if receiver != "" {
+		return "(" + pkgPath + "." + receiver + ")." + name
+	}
+	return pkgPath + "." + name
+}
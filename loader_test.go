@@ -0,0 +1,104 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// writeProjectFiles creates a temporary directory containing the given files
+func writeProjectFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	tmpDir, err := os.MkdirTemp("", "test-project-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(tmpDir) })
+
+	for name, content := range files {
+		path := filepath.Join(tmpDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create dir for %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	return tmpDir
+}
+
+func TestQualifiedFuncKey(t *testing.T) {
+	tests := []struct {
+		pkgPath  string
+		receiver string
+		name     string
+		want     string
+	}{
+		{"example.com/pkg", "", "Foo", "example.com/pkg.Foo"},
+		{"example.com/pkg", "Store", "Get", "(example.com/pkg.Store).Get"},
+	}
+
+	for _, tt := range tests {
+		got := qualifiedFuncKey(tt.pkgPath, tt.receiver, tt.name)
+		if got != tt.want {
+			t.Errorf("qualifiedFuncKey(%q, %q, %q) = %q, want %q", tt.pkgPath, tt.receiver, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLoadTypedPackages_NoModule(t *testing.T) {
+	tmpDir := writeProjectFiles(t, map[string]string{
+		"source.go": "package testpkg\n\nfunc Foo() {}\n",
+	})
+
+	if _, err := loadTypedPackages(tmpDir); err == nil {
+		t.Error("Expected error for directory without go.mod, got nil")
+	}
+}
+
+func TestTypeContext_CalleeIdent(t *testing.T) {
+	code := `package p
+
+func Map[T any](x T) T { return x }
+
+var handlers = map[string]func(){}
+
+func use() {
+	Map[int](1)
+	handlers["a"]()
+	Map(2)
+}
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "p.go", code, 0)
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+	info := &types.Info{Uses: make(map[*ast.Ident]types.Object), Instances: make(map[*ast.Ident]types.Instance)}
+	if _, err := (&types.Config{}).Check("p", fset, []*ast.File{file}, info); err != nil {
+		t.Fatalf("Failed to type-check: %v", err)
+	}
+
+	tc := &typeContext{info: info}
+	var got []string
+	ast.Inspect(file.Decls[len(file.Decls)-1], func(n ast.Node) bool {
+		if call, ok := n.(*ast.CallExpr); ok {
+			name := "<nil>"
+			if ident := tc.calleeIdent(call); ident != nil {
+				name = ident.Name
+			}
+			got = append(got, name)
+		}
+		return true
+	})
+
+	// handlers["a"] indexes a map, it does not instantiate a generic function
+	if want := []string{"Map", "<nil>", "Map"}; !slices.Equal(got, want) {
+		t.Errorf("calleeIdent() = %v, want %v", got, want)
+	}
+}
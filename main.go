@@ -5,22 +5,110 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime/debug"
+	"strings"
 )
 
+// version is the testvet version, set at build time with
+// -ldflags "-X main.version=v1.2.3"
+var version string
+
+// toolVersion returns the version set at build time, or the module version
+// when installed with go install
+func toolVersion() string {
+	if version != "" {
+		return version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "(devel)"
+}
+
+// listFlag is a string flag that can be repeated, collecting every value
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
 func main() {
 	var dir string
 	var excludePrivate bool
 	var verbose bool
 	var threshold float64
 	var useCoverage bool
+	var callGraph string
+	var maxDepth int
+	var requireBlackBox bool
+	var coverProfiles string
+	var testMatrix string
+	var covDirs string
+	var testArgs string
+	var tags string
+	var testEnv listFlag
+	var compareTags bool
+	var coverPkg string
+	var maxHits int
+	var showUncovered bool
+	var format string
+	var errorOn string
 
 	flag.StringVar(&dir, "dir", ".", "Directory to analyze")
 	flag.BoolVar(&excludePrivate, "exclude-private", false, "Exclude private (unexported) functions from analysis")
 	flag.BoolVar(&verbose, "verbose", false, "Show verbose output")
 	flag.Float64Var(&threshold, "threshold", 0, "Show functions with coverage below this percentage (0 to disable)")
 	flag.BoolVar(&useCoverage, "use-coverage", true, "Use coverage data to filter out indirectly tested functions (runs go test)")
+	flag.StringVar(&callGraph, "callgraph", "", "Consider functions reachable from tests through a static call graph as tested (cha or rta)")
+	flag.IntVar(&maxDepth, "max-depth", 0, "Maximum call depth followed from a test in call graph mode (0 for unlimited)")
+	flag.BoolVar(&requireBlackBox, "require-blackbox", false, "Report exported functions not called from external test packages (package foo_test)")
+	flag.StringVar(&coverProfiles, "coverprofile", "", "Comma-separated coverage profiles to use instead of running go test (merged if several)")
+	flag.BoolVar(&showUncovered, "show-uncovered", false, "Print the uncovered source lines of low coverage functions (with -threshold)")
+	flag.IntVar(&maxHits, "max-hits", 0, "Report covered functions none of whose blocks ran more than this many times (0 to disable)")
+	flag.StringVar(&coverPkg, "coverpkg", "", "Count coverage of these packages (e.g. ./...) from the tests of every package and report which packages' tests cover each function")
+	flag.StringVar(&covDirs, "covdir", "", "Comma-separated GOCOVERDIR directories of integration runs (go build -cover) to merge with unit test coverage")
+	flag.StringVar(&testArgs, "test-args", "", "Extra go test arguments for the coverage runs, e.g. \"-short -race -timeout=5m\"")
+	flag.StringVar(&tags, "tags", "", "Build tags of the coverage runs; separate several tag sets with ';' to run each and union the results (\"default\" for no tags)")
+	flag.Var(&testEnv, "test-env", "Environment variable KEY=VALUE for the coverage runs (repeatable)")
+	flag.BoolVar(&compareTags, "compare-tags", false, "Report functions whose coverage differs between the tag sets of -tags")
+	flag.StringVar(&testMatrix, "test-matrix", "", "Run each test (test) or each package's tests (package) in isolation and report which tests cover which functions")
+	flag.StringVar(&format, "format", "text", "Output format: text, json, sarif, github (GitHub Actions annotations) or junit")
+	flag.StringVar(&errorOn, "error-on", "", "Comma-separated finding kinds annotated as errors instead of warnings with -format github (untested-function, misplaced-test, low-coverage)")
 	flag.Parse()
 
+	switch format {
+	case "text", "json", "sarif", "github", "junit":
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unknown output format %q (want text, json, sarif, github or junit)\n", format)
+		os.Exit(1)
+	}
+	switch callGraph {
+	case "", callGraphCHA, callGraphRTA:
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unknown call graph algorithm %q (want %s or %s)\n", callGraph, callGraphCHA, callGraphRTA)
+		os.Exit(1)
+	}
+	switch testMatrix {
+	case "", matrixByTest, matrixByPackage:
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unknown test matrix granularity %q (want %s or %s)\n", testMatrix, matrixByTest, matrixByPackage)
+		os.Exit(1)
+	}
+	if covDirs != "" && !useCoverage && threshold <= 0 && maxHits <= 0 {
+		fmt.Fprintf(os.Stderr, "Error: -covdir requires -use-coverage, -threshold or -max-hits\n")
+		os.Exit(1)
+	}
+	errorKinds, err := parseErrorKinds(errorOn)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: -error-on: %v\n", err)
+		os.Exit(1)
+	}
+
 	// Convert to absolute path
 	absDir, err := filepath.Abs(dir)
 	if err != nil {
@@ -28,31 +116,106 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Get coverage map if -use-coverage is set
-	var coverageMap map[string]float64
-	if useCoverage {
-		coverageMap, err = getCoverageMap(absDir, verbose)
+	goTest := goTestConfig{
+		args:     strings.Fields(testArgs),
+		env:      testEnv,
+		tagSets:  parseTagSets(tags),
+		coverPkg: coverPkg,
+	}
+	if compareTags && len(goTest.tagSets) < 2 {
+		fmt.Fprintf(os.Stderr, "Warning: -compare-tags requires at least two tag sets in -tags\n")
+	}
+
+	// Run the tests with coverage once (per tag set) if -use-coverage,
+	// -threshold or -max-hits is set, unless existing coverage profiles were given
+	var coverage *coverageData
+	if coverProfiles != "" {
+		coverage, err = loadCoverProfiles(absDir, strings.Split(coverProfiles, ","), verbose)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading coverage profiles: %v\n", err)
+			os.Exit(1)
+		}
+	} else if useCoverage || threshold > 0 || maxHits > 0 {
+		coverage, err = runCoverage(absDir, goTest, verbose)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Warning: coverage analysis failed: %v\n", err)
-			// Continue without coverage filtering
+			// Continue without coverage data
 		}
 	}
 
-	result, err := analyzeProject(absDir, excludePrivate, verbose, coverageMap)
+	if coverage != nil && len(coverage.failed) > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: tests failed in %d packages, their coverage is unknown\n", len(coverage.failed))
+	}
+
+	// Union the coverage of integration runs of binaries built with -cover
+	if covDirs != "" {
+		coverage, err = addIntegrationCoverage(absDir, coverage, strings.Split(covDirs, ","), verbose)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading integration coverage: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	var classifyCoverage *coverageData
+	if useCoverage {
+		classifyCoverage = coverage
+	}
+
+	opts := analysisOptions{
+		excludePrivate: excludePrivate,
+		verbose:        verbose,
+		callGraph:      callGraph,
+		maxDepth:       maxDepth,
+
+		requireBlackBox: requireBlackBox,
+		testMatrix:      testMatrix,
+
+		goTest: goTest,
+	}
+	result, err := analyzeProject(absDir, opts, classifyCoverage)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error analyzing project: %v\n", err)
 		os.Exit(1)
 	}
 
-	// Run coverage analysis if threshold is set
-	if threshold > 0 {
-		lowCoverage, err := analyzeCoverage(absDir, threshold, verbose)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: coverage analysis failed: %v\n", err)
+	// Report low coverage functions if threshold is set
+	if threshold > 0 && coverage != nil {
+		result.LowCoverageFuncs = coverage.lowCoverage(threshold)
+		if showUncovered {
+			if err := addUncoveredSource(absDir, result.LowCoverageFuncs); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			}
+		}
+	}
+	if maxHits > 0 && coverage != nil {
+		if coverage.hasHitCounts() {
+			result.BarelyExercisedFuncs = coverage.barelyExercised(maxHits)
 		} else {
-			result.LowCoverageFuncs = lowCoverage
+			fmt.Fprintf(os.Stderr, "Warning: -max-hits requires coverage profiles in count or atomic mode, skipping\n")
 		}
 	}
+	if coverage != nil {
+		result.FailedPackages = coverage.failed
+		result.CrossPackageFuncs = coverage.crossPackage()
+	}
+	if compareTags && coverage != nil {
+		result.TagSets, result.TagSetCoverageDiffs = coverage.compareTagSets()
+	}
 
-	printResults(result, absDir)
+	switch format {
+	case "json":
+		err = writeJSONReport(os.Stdout, result, absDir)
+	case "sarif":
+		err = writeSARIFReport(os.Stdout, result, absDir)
+	case "github":
+		err = writeGitHubAnnotations(os.Stdout, result, absDir, errorKinds)
+	case "junit":
+		err = writeJUnitReport(os.Stdout, result)
+	default:
+		printResults(result, absDir)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing %s report: %v\n", format, err)
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/tools/cover"
+)
+
+// Granularities of the per-test coverage matrix
+const (
+	matrixByTest    = "test"
+	matrixByPackage = "package"
+)
+
+// matrixUnit is a test (or a package's tests) run in isolation
+type matrixUnit struct {
+	test TestRef
+	args []string // go test arguments selecting the unit
+}
+
+// coverageRun is the coverage of a single isolated test run
+type coverageRun struct {
+	test   TestRef
+	funcs  map[funcPosition]bool // functions with at least one statement executed
+	blocks map[coveredBlock]bool // executed profile blocks
+}
+
+// coveredBlock identifies a block of a coverage profile
+type coveredBlock struct {
+	file                string
+	startLine, startCol int
+	endLine, endCol     int
+}
+
+// coverageMatrix records which functions and blocks every isolated test run covered
+type coverageMatrix struct {
+	granularity string
+	runs        []coverageRun
+}
+
+// buildCoverageMatrix runs every test (granularity "test") or every package's
+// tests (granularity "package") in isolation with its own coverage profile.
+// Runs that fail are reported as warnings and left out of the matrix.
+func buildCoverageMatrix(dir string, fileTests map[string][]TestInfo, granularity string, cfg goTestConfig, verbose bool) (*coverageMatrix, error) {
+	units, err := matrixUnits(fileTests, granularity)
+	if err != nil {
+		return nil, err
+	}
+
+	runs := make([]*coverageRun, len(units))
+	sem := make(chan struct{}, runtime.GOMAXPROCS(0))
+	var wg sync.WaitGroup
+	for i, unit := range units {
+		wg.Go(func() {
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			run, err := runMatrixUnit(dir, unit, cfg, verbose)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: coverage run of %s failed: %v\n", unit.test.Name, err)
+				return
+			}
+			runs[i] = run
+		})
+	}
+	wg.Wait()
+
+	matrix := &coverageMatrix{granularity: granularity}
+	for _, run := range runs {
+		if run != nil {
+			matrix.runs = append(matrix.runs, *run)
+		}
+	}
+	return matrix, nil
+}
+
+// matrixUnits returns the isolated runs for the tests, sorted by file and line
+// (or package directory)
+func matrixUnits(fileTests map[string][]TestInfo, granularity string) ([]matrixUnit, error) {
+	var units []matrixUnit
+	switch granularity {
+	case matrixByTest:
+		for file, tests := range fileTests {
+			pkgDir := filepath.Dir(file)
+			for _, test := range tests {
+				// Benchmarks and examples are not run by go test -run alone
+				if !strings.HasPrefix(test.Name, "Test") && !strings.HasPrefix(test.Name, "Fuzz") {
+					continue
+				}
+				units = append(units, matrixUnit{
+					test: TestRef{Name: test.Name, File: file, Line: test.Line, Package: pkgDir},
+					args: []string{"-run", "^" + regexp.QuoteMeta(test.Name) + "$", packagePattern(pkgDir)},
+				})
+			}
+		}
+	case matrixByPackage:
+		seen := make(map[string]bool)
+		for file := range fileTests {
+			pkgDir := filepath.Dir(file)
+			if seen[pkgDir] {
+				continue
+			}
+			seen[pkgDir] = true
+			units = append(units, matrixUnit{
+				test: TestRef{Name: packagePattern(pkgDir), Package: pkgDir},
+				args: []string{packagePattern(pkgDir)},
+			})
+		}
+	default:
+		return nil, fmt.Errorf("unknown test matrix granularity %q (want %q or %q)", granularity, matrixByTest, matrixByPackage)
+	}
+
+	sort.Slice(units, func(i, j int) bool {
+		if units[i].test.Package != units[j].test.Package {
+			return units[i].test.Package < units[j].test.Package
+		}
+		if units[i].test.File != units[j].test.File {
+			return units[i].test.File < units[j].test.File
+		}
+		return units[i].test.Line < units[j].test.Line
+	})
+	return units, nil
+}
+
+// packagePattern returns the go test package pattern for a directory relative to the module
+func packagePattern(pkgDir string) string {
+	if pkgDir == "." {
+		return "."
+	}
+	return "./" + filepath.ToSlash(pkgDir)
+}
+
+// runMatrixUnit runs a single unit under every build tag set and records the
+// functions and blocks it covered
+func runMatrixUnit(dir string, unit matrixUnit, cfg goTestConfig, verbose bool) (*coverageRun, error) {
+	var sets [][]*cover.Profile
+	for _, tags := range cfg.runTagSets() {
+		testRun, err := runGoTestCoverage(dir, cfg, tags, unit.args, verbose)
+		if err != nil {
+			return nil, err
+		}
+		if len(testRun.failed) > 0 {
+			return nil, fmt.Errorf("tests failed in %s", testRun.failed[0].Package)
+		}
+		sets = append(sets, testRun.profiles)
+	}
+	profiles, err := maxCoverProfiles(sets...)
+	if err != nil {
+		return nil, err
+	}
+	coverage, err := coverageFromProfiles(dir, profiles)
+	if err != nil {
+		return nil, err
+	}
+
+	run := &coverageRun{
+		test:   unit.test,
+		funcs:  make(map[funcPosition]bool),
+		blocks: coveredBlocks(profiles),
+	}
+	for _, f := range coverage.funcs {
+		if f.Coverage > 0 {
+			run.funcs[funcPosition{File: f.File, Line: f.Line}] = true
+		}
+	}
+	return run, nil
+}
+
+// coveredBlocks returns the executed blocks of coverage profiles
+func coveredBlocks(profiles []*cover.Profile) map[coveredBlock]bool {
+	blocks := make(map[coveredBlock]bool)
+	for _, p := range profiles {
+		for _, b := range p.Blocks {
+			if b.Count > 0 {
+				blocks[coveredBlock{p.FileName, b.StartLine, b.StartCol, b.EndLine, b.EndCol}] = true
+			}
+		}
+	}
+	return blocks
+}
+
+// funcReport returns, for every function covered by at least one run, the
+// tests that covered it, and the functions none of whose covering tests
+// target them (see testTargets)
+func (m *coverageMatrix) funcReport(functions []FuncInfo) ([]FuncTests, []FuncTests) {
+	var all, incidental []FuncTests
+	for _, f := range functions {
+		var tests []TestRef
+		targeted := false
+		for _, run := range m.runs {
+			if !run.funcs[funcPosition{File: f.File, Line: f.Line}] {
+				continue
+			}
+			tests = append(tests, run.test)
+			if m.testTargets(run.test, f) {
+				targeted = true
+			}
+		}
+		if len(tests) == 0 {
+			continue
+		}
+		all = append(all, FuncTests{Func: f, Tests: tests})
+		if !targeted {
+			incidental = append(incidental, FuncTests{Func: f, Tests: tests})
+		}
+	}
+	return all, incidental
+}
+
+// testTargets reports whether a test is aimed at a function: per package, the
+// function must be in the tested package; per test, the test name must name the
+// function or its receiver (TestFoo, TestType_Method, FuzzFoo)
+func (m *coverageMatrix) testTargets(test TestRef, f FuncInfo) bool {
+	if m.granularity == matrixByPackage {
+		return filepath.Dir(f.File) == test.Package
+	}
+
+	name := test.Name
+	if rest, ok := strings.CutPrefix(name, "Fuzz"); ok {
+		name = "Test" + rest
+	}
+	targets := append(strings.Split(f.Name, "."), f.Receiver)
+	for _, candidate := range extractFunctionNamesFromTest(name) {
+		for _, target := range targets {
+			if target != "" && strings.HasPrefix(strings.ToLower(target), strings.ToLower(candidate)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// redundantTests returns the tests all of whose executed blocks are also
+// executed by at least one other test
+func (m *coverageMatrix) redundantTests() []TestRef {
+	blockRuns := make(map[coveredBlock]int)
+	for _, run := range m.runs {
+		for b := range run.blocks {
+			blockRuns[b]++
+		}
+	}
+
+	var redundant []TestRef
+	for _, run := range m.runs {
+		unique := false
+		for b := range run.blocks {
+			if blockRuns[b] == 1 {
+				unique = true
+				break
+			}
+		}
+		if !unique {
+			redundant = append(redundant, run.test)
+		}
+	}
+	return redundant
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMatrixUnits(t *testing.T) {
+	fileTests := map[string][]TestInfo{
+		filepath.Join("b", "b_test.go"): {
+			{Name: "TestB", Line: 5},
+		},
+		"a_test.go": {
+			{Name: "TestSecond", Line: 20},
+			{Name: "TestFirst", Line: 10},
+			{Name: "BenchmarkFirst", Line: 30},
+			{Name: "FuzzFirst", Line: 40},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		granularity string
+		wantNames   []string
+		wantArgs    [][]string
+	}{
+		{
+			name:        "per test",
+			granularity: matrixByTest,
+			wantNames:   []string{"TestFirst", "TestSecond", "FuzzFirst", "TestB"},
+			wantArgs: [][]string{
+				{"-run", "^TestFirst$", "."},
+				{"-run", "^TestSecond$", "."},
+				{"-run", "^FuzzFirst$", "."},
+				{"-run", "^TestB$", "./b"},
+			},
+		},
+		{
+			name:        "per package",
+			granularity: matrixByPackage,
+			wantNames:   []string{".", "./b"},
+			wantArgs:    [][]string{{"."}, {"./b"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			units, err := matrixUnits(fileTests, tt.granularity)
+			if err != nil {
+				t.Fatalf("matrixUnits failed: %v", err)
+			}
+			var names []string
+			var args [][]string
+			for _, u := range units {
+				names = append(names, u.test.Name)
+				args = append(args, u.args)
+			}
+			if !reflect.DeepEqual(names, tt.wantNames) {
+				t.Errorf("names = %v, want %v", names, tt.wantNames)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("args = %v, want %v", args, tt.wantArgs)
+			}
+		})
+	}
+
+	if _, err := matrixUnits(fileTests, "file"); err == nil {
+		t.Error("Expected error for unknown granularity")
+	}
+}
+
+func TestCoverageMatrix_TestTargets(t *testing.T) {
+	tests := []struct {
+		name        string
+		granularity string
+		test        TestRef
+		f           FuncInfo
+		want        bool
+	}{
+		{"function name", matrixByTest, TestRef{Name: "TestParse"}, FuncInfo{Name: "Parse"}, true},
+		{"case insensitive prefix", matrixByTest, TestRef{Name: "TestParse_Errors"}, FuncInfo{Name: "parseHeader"}, true},
+		{"method", matrixByTest, TestRef{Name: "TestServer_Start"}, FuncInfo{Name: "Start", Receiver: "Server"}, true},
+		{"receiver", matrixByTest, TestRef{Name: "TestServer"}, FuncInfo{Name: "stop", Receiver: "Server"}, true},
+		{"function literal unit", matrixByTest, TestRef{Name: "TestHandlers"}, FuncInfo{Name: "Handlers.Get"}, true},
+		{"fuzz test", matrixByTest, TestRef{Name: "FuzzParse"}, FuncInfo{Name: "Parse"}, true},
+		{"other function", matrixByTest, TestRef{Name: "TestRun"}, FuncInfo{Name: "Parse"}, false},
+		{"same package", matrixByPackage, TestRef{Name: "./a", Package: "a"}, FuncInfo{Name: "Parse", File: filepath.Join("a", "a.go")}, true},
+		{"other package", matrixByPackage, TestRef{Name: "./a", Package: "a"}, FuncInfo{Name: "Parse", File: filepath.Join("b", "b.go")}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &coverageMatrix{granularity: tt.granularity}
+			if got := m.testTargets(tt.test, tt.f); got != tt.want {
+				t.Errorf("testTargets(%s, %s) = %v, want %v", tt.test.Name, tt.f.Name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCoverageMatrix_Report(t *testing.T) {
+	parse := FuncInfo{Name: "Parse", File: "parse.go", Line: 3}
+	trim := FuncInfo{Name: "trim", File: "parse.go", Line: 10}
+	unused := FuncInfo{Name: "Unused", File: "parse.go", Line: 20}
+	parseBlock := coveredBlock{"example.com/p/parse.go", 3, 20, 8, 2}
+	trimBlock := coveredBlock{"example.com/p/parse.go", 10, 25, 12, 2}
+
+	testParse := TestRef{Name: "TestParse", File: "parse_test.go", Line: 5}
+	testParseAgain := TestRef{Name: "TestParseAgain", File: "parse_test.go", Line: 12}
+	m := &coverageMatrix{
+		granularity: matrixByTest,
+		runs: []coverageRun{
+			{
+				test:   testParse,
+				funcs:  map[funcPosition]bool{{File: "parse.go", Line: 3}: true, {File: "parse.go", Line: 10}: true},
+				blocks: map[coveredBlock]bool{parseBlock: true, trimBlock: true},
+			},
+			{
+				test:   testParseAgain,
+				funcs:  map[funcPosition]bool{{File: "parse.go", Line: 3}: true},
+				blocks: map[coveredBlock]bool{parseBlock: true},
+			},
+		},
+	}
+
+	all, incidental := m.funcReport([]FuncInfo{parse, trim, unused})
+	wantAll := []FuncTests{
+		{Func: parse, Tests: []TestRef{testParse, testParseAgain}},
+		{Func: trim, Tests: []TestRef{testParse}},
+	}
+	if !reflect.DeepEqual(all, wantAll) {
+		t.Errorf("funcReport all = %v, want %v", all, wantAll)
+	}
+	wantIncidental := []FuncTests{{Func: trim, Tests: []TestRef{testParse}}}
+	if !reflect.DeepEqual(incidental, wantIncidental) {
+		t.Errorf("funcReport incidental = %v, want %v", incidental, wantIncidental)
+	}
+
+	if got, want := m.redundantTests(), []TestRef{testParseAgain}; !reflect.DeepEqual(got, want) {
+		t.Errorf("redundantTests() = %v, want %v", got, want)
+	}
+}
+
+func TestAnalyzeProject_TestMatrix(t *testing.T) {
+	tmpDir := writeProjectFiles(t, map[string]string{
+		"go.mod": "module example.com/matrix\n\ngo 1.21\n",
+		"calc.go": `package calc
+
+func Add(a, b int) int { return norm(a) + norm(b) }
+
+func norm(x int) int {
+	if x < 0 {
+		return 0
+	}
+	return x
+}
+
+func Unused() {}
+`,
+		"calc_test.go": `package calc
+
+import "testing"
+
+func TestAdd(t *testing.T) {
+	if Add(1, -2) != 1 {
+		t.Fatal("unexpected sum")
+	}
+}
+
+func TestAddAgain(t *testing.T) {
+	if Add(2, 3) != 5 {
+		t.Fatal("unexpected sum")
+	}
+}
+`,
+	})
+
+	result, err := analyzeProject(tmpDir, analysisOptions{testMatrix: matrixByTest}, nil)
+	if err != nil {
+		t.Fatalf("analyzeProject failed: %v", err)
+	}
+
+	matrix := make(map[string]string)
+	for _, ft := range result.TestMatrix {
+		var names []string
+		for _, test := range ft.Tests {
+			names = append(names, test.Name)
+		}
+		matrix[ft.Func.Name] = strings.Join(names, ",")
+	}
+	wantMatrix := map[string]string{"Add": "TestAdd,TestAddAgain", "norm": "TestAdd,TestAddAgain"}
+	if !reflect.DeepEqual(matrix, wantMatrix) {
+		t.Errorf("TestMatrix = %v, want %v", matrix, wantMatrix)
+	}
+
+	if len(result.IncidentallyCoveredFuncs) != 1 || result.IncidentallyCoveredFuncs[0].Func.Name != "norm" {
+		t.Errorf("IncidentallyCoveredFuncs = %v, want only norm", result.IncidentallyCoveredFuncs)
+	}
+	if len(result.RedundantTests) != 1 || result.RedundantTests[0].Name != "TestAddAgain" {
+		t.Errorf("RedundantTests = %v, want only TestAddAgain", result.RedundantTests)
+	}
+}
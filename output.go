@@ -12,36 +12,94 @@ func printResults(result *AnalysisResult, baseDir string) {
 	fmt.Printf("Project: %s\n\n", baseDir)
 
 	// Functions without tests
-	fmt.Println("-" + strings.Repeat("-", 79))
-	fmt.Printf("FUNCTIONS WITHOUT TEST COVERAGE (%d)\n", len(result.FunctionsWithoutTests))
-	fmt.Println("-" + strings.Repeat("-", 79))
+	printSectionHeader(fmt.Sprintf("FUNCTIONS WITHOUT TEST COVERAGE (%d)", len(result.FunctionsWithoutTests)))
 
 	if len(result.FunctionsWithoutTests) == 0 {
 		fmt.Println("All functions have test coverage!")
 	} else {
-		currentFile := ""
-		for _, f := range result.FunctionsWithoutTests {
-			if f.File != currentFile {
-				if currentFile != "" {
-					fmt.Println()
-				}
-				currentFile = f.File
-				fmt.Printf("\n%s:\n", f.File)
+		printFuncsByFile(result.FunctionsWithoutTests)
+	}
+
+	fmt.Println()
+
+	// Packages whose tests failed during the coverage run
+	if len(result.FailedPackages) > 0 {
+		printSectionHeader(fmt.Sprintf("FAILING PACKAGES (%d)", len(result.FailedPackages)))
+
+		for _, pkg := range result.FailedPackages {
+			fmt.Printf("\n%s:\n", pkg.Package)
+			if pkg.BuildFailed {
+				fmt.Println("  build failed")
 			}
-			funcDesc := f.Name
-			if f.Receiver != "" {
-				funcDesc = fmt.Sprintf("(%s).%s", f.Receiver, f.Name)
+			for _, test := range pkg.Tests {
+				fmt.Printf("  FAIL %s\n", test)
 			}
-			fmt.Printf("  Line %d: %s\n", f.Line, funcDesc)
 		}
+
+		fmt.Println()
 	}
 
-	fmt.Println()
+	// Functions in failing packages whose coverage could not be measured
+	if len(result.CoverageUnknownFuncs) > 0 {
+		printSectionHeader(fmt.Sprintf("FUNCTIONS WITH UNKNOWN COVERAGE (%d)", len(result.CoverageUnknownFuncs)))
+		printFuncsByFile(result.CoverageUnknownFuncs)
+		fmt.Println()
+	}
+
+	// Functions considered tested only through heuristics or coverage
+	if len(result.HeuristicallyTestedFuncs) > 0 {
+		printSectionHeader(fmt.Sprintf("HEURISTICALLY TESTED FUNCTIONS (%d)", len(result.HeuristicallyTestedFuncs)))
+		printByFile(result.HeuristicallyTestedFuncs, func(f FuncInfo) string { return f.File }, func(f FuncInfo) {
+			fmt.Printf("  Line %d: %s [%s: %s]\n", f.Line, funcDescription(f.Receiver, f.Name), f.Verdict, f.Reason)
+		})
+		fmt.Println()
+	}
+
+	// Functions used as values in tests but never called
+	if len(result.ReferencedOnlyFuncs) > 0 {
+		printSectionHeader(fmt.Sprintf("FUNCTIONS ONLY REFERENCED FROM TESTS (%d)", len(result.ReferencedOnlyFuncs)))
+		printFuncsByFile(result.ReferencedOnlyFuncs)
+		fmt.Println()
+	}
+
+	// Functions only executed by integration runs (if -covdir was set)
+	if len(result.IntegrationOnlyFuncs) > 0 {
+		printSectionHeader(fmt.Sprintf("FUNCTIONS COVERED ONLY BY INTEGRATION TESTS (%d)", len(result.IntegrationOnlyFuncs)))
+		printByFile(result.IntegrationOnlyFuncs, func(f FuncInfo) string { return f.File }, func(f FuncInfo) {
+			fmt.Printf("  Line %d: %s (%s)\n", f.Line, funcDescription(f.Receiver, f.Name), f.Reason)
+		})
+		fmt.Println()
+	}
+
+	// Exported functions only called from tests inside their package (if -require-blackbox was set)
+	if len(result.WhiteBoxOnlyFuncs) > 0 {
+		printSectionHeader(fmt.Sprintf("EXPORTED FUNCTIONS WITHOUT BLACK-BOX TESTS (%d)", len(result.WhiteBoxOnlyFuncs)))
+		printByFile(result.WhiteBoxOnlyFuncs, func(f FuncInfo) string { return f.File }, func(f FuncInfo) {
+			fmt.Printf("  Line %d: %s (%s tests only)\n", f.Line, funcDescription(f.Receiver, f.Name), f.TestStyle)
+		})
+		fmt.Println()
+	}
+
+	// Functions only reached through the call graph (if call graph mode was enabled)
+	if len(result.IndirectlyTestedFuncs) > 0 {
+		printSectionHeader(fmt.Sprintf("INDIRECTLY TESTED FUNCTIONS (%d)", len(result.IndirectlyTestedFuncs)))
+		printByFile(result.IndirectlyTestedFuncs, func(r ReachedFunc) string { return r.Func.File }, func(r ReachedFunc) {
+			fmt.Printf("  Line %d: %s (via %s)\n", r.Func.Line, funcDescription(r.Func.Receiver, r.Func.Name), strings.Join(r.Chain, " -> "))
+		})
+		fmt.Println()
+	}
+
+	// Interface implementations never exercised with a concrete receiver
+	if len(result.UnexercisedImpls) > 0 {
+		printSectionHeader(fmt.Sprintf("UNEXERCISED INTERFACE IMPLEMENTATIONS (%d)", len(result.UnexercisedImpls)))
+		printByFile(result.UnexercisedImpls, func(u UnexercisedImpl) string { return u.Func.File }, func(u UnexercisedImpl) {
+			fmt.Printf("  Line %d: %s implements %s\n", u.Func.Line, funcDescription(u.Func.Receiver, u.Func.Name), u.Interface)
+		})
+		fmt.Println()
+	}
 
 	// Misplaced tests
-	fmt.Println("-" + strings.Repeat("-", 79))
-	fmt.Printf("MISPLACED TESTS (%d)\n", len(result.MisplacedTests))
-	fmt.Println("-" + strings.Repeat("-", 79))
+	printSectionHeader(fmt.Sprintf("MISPLACED TESTS (%d)", len(result.MisplacedTests)))
 
 	if len(result.MisplacedTests) == 0 {
 		fmt.Println("All tests are in the correct files!")
@@ -56,22 +114,78 @@ func printResults(result *AnalysisResult, baseDir string) {
 	// Low coverage functions (if threshold was set)
 	if len(result.LowCoverageFuncs) > 0 {
 		fmt.Println()
-		fmt.Println("-" + strings.Repeat("-", 79))
 		threshold := result.LowCoverageFuncs[0].Threshold
-		fmt.Printf("LOW COVERAGE FUNCTIONS (below %.1f%%) (%d)\n", threshold, len(result.LowCoverageFuncs))
-		fmt.Println("-" + strings.Repeat("-", 79))
-
-		currentFile := ""
-		for _, f := range result.LowCoverageFuncs {
-			if f.File != currentFile {
-				if currentFile != "" {
-					fmt.Println()
-				}
-				currentFile = f.File
-				fmt.Printf("\n%s:\n", f.File)
+		printSectionHeader(fmt.Sprintf("LOW COVERAGE FUNCTIONS (below %.1f%%) (%d)", threshold, len(result.LowCoverageFuncs)))
+		printByFile(result.LowCoverageFuncs, func(f LowCoverageFunc) string { return f.File }, printLowCoverageFunc)
+	}
+
+	// Functions whose blocks ran only a few times (if -max-hits was set)
+	if len(result.BarelyExercisedFuncs) > 0 {
+		fmt.Println()
+		limit := result.BarelyExercisedFuncs[0].Limit
+		printSectionHeader(fmt.Sprintf("BARELY EXERCISED FUNCTIONS (at most %d hits) (%d)", limit, len(result.BarelyExercisedFuncs)))
+		fmt.Println()
+
+		for _, f := range result.BarelyExercisedFuncs {
+			fmt.Printf("  %s:%d: %s (max %d hits, %.1f%%)\n", f.File, f.Line, funcDescription(f.Receiver, f.Name), f.MaxHits, f.Coverage)
+		}
+	}
+
+	// Functions executed by the tests of other packages (if -coverpkg was set)
+	if len(result.CrossPackageFuncs) > 0 {
+		fmt.Println()
+		printSectionHeader(fmt.Sprintf("CROSS-PACKAGE COVERAGE (%d)", len(result.CrossPackageFuncs)))
+		printByFile(result.CrossPackageFuncs, func(f CrossPackageFunc) string { return f.File }, func(f CrossPackageFunc) {
+			fmt.Printf("  Line %d: %s (%.1f%%) <- %s\n", f.Line, funcDescription(f.Receiver, f.Name), f.Coverage, strings.Join(f.Packages, ", "))
+		})
+	}
+
+	// Coverage differences between build tag sets (if -compare-tags was set)
+	if len(result.TagSetCoverageDiffs) > 0 {
+		fmt.Println()
+		printSectionHeader(fmt.Sprintf("COVERAGE BY BUILD TAG SET (%s) (%d)", strings.Join(result.TagSets, " vs "), len(result.TagSetCoverageDiffs)))
+		printByFile(result.TagSetCoverageDiffs, func(f TagSetCoverage) string { return f.File }, func(f TagSetCoverage) {
+			perSet := make([]string, len(f.Coverage))
+			for i, cov := range f.Coverage {
+				perSet[i] = fmt.Sprintf("%s: %.1f%%", result.TagSets[i], cov)
+			}
+			fmt.Printf("  Line %d: %s (%s)\n", f.Line, funcDescription(f.Receiver, f.Name), strings.Join(perSet, ", "))
+		})
+	}
+
+	// Per-test coverage matrix (if -test-matrix was set)
+	if len(result.TestMatrix) > 0 {
+		fmt.Println()
+		printSectionHeader(fmt.Sprintf("TEST COVERAGE MATRIX (%d)", len(result.TestMatrix)))
+		printByFile(result.TestMatrix, func(ft FuncTests) string { return ft.Func.File }, func(ft FuncTests) {
+			fmt.Printf("  Line %d: %s <- %s\n", ft.Func.Line, funcDescription(ft.Func.Receiver, ft.Func.Name), testNames(ft.Tests))
+		})
+	}
+
+	if len(result.IncidentallyCoveredFuncs) > 0 {
+		fmt.Println()
+		printSectionHeader(fmt.Sprintf("INCIDENTALLY COVERED FUNCTIONS (%d)", len(result.IncidentallyCoveredFuncs)))
+		printByFile(result.IncidentallyCoveredFuncs, func(ft FuncTests) string { return ft.Func.File }, func(ft FuncTests) {
+			fmt.Printf("  Line %d: %s (only by %s)\n", ft.Func.Line, funcDescription(ft.Func.Receiver, ft.Func.Name), testNames(ft.Tests))
+		})
+	}
+
+	if len(result.RedundantTests) > 0 {
+		fmt.Println()
+		printSectionHeader(fmt.Sprintf("TESTS WITHOUT UNIQUE COVERAGE (%d)", len(result.RedundantTests)))
+		testFile := func(t TestRef) string {
+			if t.File == "" {
+				return t.Package
 			}
-			fmt.Printf("  Line %d: %s (%.1f%%)\n", f.Line, f.Name, f.Coverage)
+			return t.File
 		}
+		printByFile(result.RedundantTests, testFile, func(t TestRef) {
+			if t.Line > 0 {
+				fmt.Printf("  Line %d: %s\n", t.Line, t.Name)
+			} else {
+				fmt.Printf("  %s\n", t.Name)
+			}
+		})
 	}
 
 	fmt.Println()
@@ -80,8 +194,111 @@ func printResults(result *AnalysisResult, baseDir string) {
 	// Summary
 	summary := fmt.Sprintf("Summary: %d functions without tests, %d misplaced tests",
 		len(result.FunctionsWithoutTests), len(result.MisplacedTests))
+	if len(result.CoverageUnknownFuncs) > 0 {
+		summary += fmt.Sprintf(", %d functions with unknown coverage", len(result.CoverageUnknownFuncs))
+	}
+	if len(result.FailedPackages) > 0 {
+		summary += fmt.Sprintf(", %d failing packages", len(result.FailedPackages))
+	}
+	if len(result.HeuristicallyTestedFuncs) > 0 {
+		summary += fmt.Sprintf(", %d heuristically tested functions", len(result.HeuristicallyTestedFuncs))
+	}
+	if len(result.ReferencedOnlyFuncs) > 0 {
+		summary += fmt.Sprintf(", %d functions only referenced from tests", len(result.ReferencedOnlyFuncs))
+	}
+	if len(result.IntegrationOnlyFuncs) > 0 {
+		summary += fmt.Sprintf(", %d functions covered only by integration tests", len(result.IntegrationOnlyFuncs))
+	}
+	if len(result.WhiteBoxOnlyFuncs) > 0 {
+		summary += fmt.Sprintf(", %d exported functions without black-box tests", len(result.WhiteBoxOnlyFuncs))
+	}
+	if len(result.IndirectlyTestedFuncs) > 0 {
+		summary += fmt.Sprintf(", %d indirectly tested functions", len(result.IndirectlyTestedFuncs))
+	}
+	if len(result.UnexercisedImpls) > 0 {
+		summary += fmt.Sprintf(", %d unexercised interface implementations", len(result.UnexercisedImpls))
+	}
 	if len(result.LowCoverageFuncs) > 0 {
 		summary += fmt.Sprintf(", %d low coverage functions", len(result.LowCoverageFuncs))
 	}
+	if len(result.BarelyExercisedFuncs) > 0 {
+		summary += fmt.Sprintf(", %d barely exercised functions", len(result.BarelyExercisedFuncs))
+	}
+	if len(result.CrossPackageFuncs) > 0 {
+		summary += fmt.Sprintf(", %d functions covered by other packages' tests", len(result.CrossPackageFuncs))
+	}
+	if len(result.TagSetCoverageDiffs) > 0 {
+		summary += fmt.Sprintf(", %d functions with coverage differing by tag set", len(result.TagSetCoverageDiffs))
+	}
+	if len(result.IncidentallyCoveredFuncs) > 0 {
+		summary += fmt.Sprintf(", %d incidentally covered functions", len(result.IncidentallyCoveredFuncs))
+	}
+	if len(result.RedundantTests) > 0 {
+		summary += fmt.Sprintf(", %d tests without unique coverage", len(result.RedundantTests))
+	}
 	fmt.Println(summary)
 }
+
+// printSectionHeader prints the title of a report section between separator lines
+func printSectionHeader(title string) {
+	fmt.Println("-" + strings.Repeat("-", 79))
+	fmt.Println(title)
+	fmt.Println("-" + strings.Repeat("-", 79))
+}
+
+// printByFile prints the items of a section under a header line per file,
+// separating the files by blank lines. Items must be sorted by file.
+func printByFile[T any](items []T, file func(T) string, printItem func(T)) {
+	currentFile := ""
+	for _, item := range items {
+		if f := file(item); f != currentFile {
+			if currentFile != "" {
+				fmt.Println()
+			}
+			currentFile = f
+			fmt.Printf("\n%s:\n", f)
+		}
+		printItem(item)
+	}
+}
+
+// printFuncsByFile prints functions grouped by file, one line each
+func printFuncsByFile(funcs []FuncInfo) {
+	printByFile(funcs, func(f FuncInfo) string { return f.File }, func(f FuncInfo) {
+		fmt.Printf("  Line %d: %s\n", f.Line, funcDescription(f.Receiver, f.Name))
+	})
+}
+
+// printLowCoverageFunc prints a low coverage function with its hit counts and
+// uncovered source lines
+func printLowCoverageFunc(f LowCoverageFunc) {
+	funcDesc := funcDescription(f.Receiver, f.Name)
+	if f.MaxHits > 0 {
+		fmt.Printf("  Line %d: %s (%.1f%%, hits %d-%d)\n", f.Line, funcDesc, f.Coverage, f.MinHits, f.MaxHits)
+	} else {
+		fmt.Printf("  Line %d: %s (%.1f%%)\n", f.Line, funcDesc, f.Coverage)
+	}
+	if len(f.Uncovered) > 0 {
+		ranges := make([]string, len(f.Uncovered))
+		for i, r := range f.Uncovered {
+			ranges[i] = r.String()
+		}
+		fmt.Printf("    uncovered: %s\n", strings.Join(ranges, ", "))
+	}
+	for i, line := range f.UncoveredSource {
+		// Separate non-adjacent ranges
+		if i > 0 && line.Line > f.UncoveredSource[i-1].Line+1 {
+			fmt.Println("         ...")
+		}
+		fmt.Printf("    %4d | %s\n", line.Line, line.Text)
+	}
+}
+
+// testNames joins the names of tests with commas
+func testNames(tests []TestRef) string {
+	names := make([]string, len(tests))
+	for i, t := range tests {
+		names[i] = t.Name
+	}
+	return strings.Join(names, ", ")
+}
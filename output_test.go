@@ -94,6 +94,118 @@ func TestPrintResults(t *testing.T) {
 				"All tests are in the correct files!",
 			},
 		},
+		{
+			name: "exported functions without black-box tests",
+			result: &AnalysisResult{
+				WhiteBoxOnlyFuncs: []FuncInfo{
+					{Name: "Format", File: "api/api.go", Line: 9, TestStyle: TestStyleWhiteBox},
+				},
+			},
+			baseDir: "/test/project",
+			wantContains: []string{
+				"EXPORTED FUNCTIONS WITHOUT BLACK-BOX TESTS (1)",
+				"Line 9: Format (white-box tests only)",
+				"1 exported functions without black-box tests",
+			},
+		},
+		{
+			name: "heuristically tested functions",
+			result: &AnalysisResult{
+				HeuristicallyTestedFuncs: []FuncInfo{
+					{Name: "Split", File: "split.go", Line: 3, Verdict: VerdictSuffixHeuristic, Reason: "matched call strings_Split"},
+					{Name: "Load", File: "svc.go", Line: 9, Receiver: "Service", Verdict: VerdictCoverageOnly, Reason: "75.0% statement coverage"},
+				},
+			},
+			baseDir: "/test/project",
+			wantContains: []string{
+				"HEURISTICALLY TESTED FUNCTIONS (2)",
+				"Line 3: Split [suffix-heuristic: matched call strings_Split]",
+				"Line 9: (Service).Load [coverage-only: 75.0% statement coverage]",
+				"2 heuristically tested functions",
+			},
+		},
+		{
+			name: "functions only referenced from tests",
+			result: &AnalysisResult{
+				ReferencedOnlyFuncs: []FuncInfo{
+					{Name: "handleHealth", File: "server.go", Line: 8},
+					{Name: "ServeUser", File: "server.go", Line: 20, Receiver: "Server"},
+				},
+			},
+			baseDir: "/test/project",
+			wantContains: []string{
+				"FUNCTIONS ONLY REFERENCED FROM TESTS (2)",
+				"Line 8: handleHealth",
+				"Line 20: (Server).ServeUser",
+				"2 functions only referenced from tests",
+			},
+		},
+		{
+			name: "failing packages and unknown coverage",
+			result: &AnalysisResult{
+				FailedPackages: []FailedPackage{
+					{Package: "example.com/app/db", Dir: "db", Tests: []string{"TestQuery", "TestQuery/empty"}},
+					{Package: "example.com/app/web", Dir: "web", BuildFailed: true},
+				},
+				CoverageUnknownFuncs: []FuncInfo{
+					{Name: "Open", File: "db/db.go", Line: 8, Verdict: VerdictCoverageUnknown},
+				},
+			},
+			baseDir: "/test/project",
+			wantContains: []string{
+				"FAILING PACKAGES (2)",
+				"example.com/app/db:\n  FAIL TestQuery\n  FAIL TestQuery/empty",
+				"example.com/app/web:\n  build failed",
+				"FUNCTIONS WITH UNKNOWN COVERAGE (1)",
+				"Line 8: Open",
+				"1 functions with unknown coverage, 2 failing packages",
+			},
+		},
+		{
+			name: "functions covered only by integration tests",
+			result: &AnalysisResult{
+				IntegrationOnlyFuncs: []FuncInfo{
+					{Name: "serve", File: "cmd/server/main.go", Line: 14, Verdict: VerdictIntegrationOnly, Reason: "66.7% statement coverage from integration runs only"},
+				},
+			},
+			baseDir: "/test/project",
+			wantContains: []string{
+				"FUNCTIONS COVERED ONLY BY INTEGRATION TESTS (1)",
+				"Line 14: serve (66.7% statement coverage from integration runs only)",
+				"1 functions covered only by integration tests",
+			},
+		},
+		{
+			name: "indirectly tested functions",
+			result: &AnalysisResult{
+				IndirectlyTestedFuncs: []ReachedFunc{
+					{Func: FuncInfo{Name: "Foo", File: "foo.go", Line: 12}, Chain: []string{"TestX", "A", "B", "Foo"}},
+					{Func: FuncInfo{Name: "Save", File: "store.go", Line: 30, Receiver: "Store"}, Chain: []string{"TestY", "(Store).Save"}},
+				},
+			},
+			baseDir: "/test/project",
+			wantContains: []string{
+				"INDIRECTLY TESTED FUNCTIONS (2)",
+				"Line 12: Foo (via TestX -> A -> B -> Foo)",
+				"Line 30: (Store).Save (via TestY -> (Store).Save)",
+				"2 indirectly tested functions",
+			},
+		},
+		{
+			name: "unexercised interface implementations",
+			result: &AnalysisResult{
+				UnexercisedImpls: []UnexercisedImpl{
+					{Func: FuncInfo{Name: "Get", File: "store.go", Line: 15, Receiver: "pgStore"}, Interface: "(Store).Get"},
+				},
+			},
+			baseDir: "/test/project",
+			wantContains: []string{
+				"UNEXERCISED INTERFACE IMPLEMENTATIONS (1)",
+				"store.go:",
+				"Line 15: (pgStore).Get implements (Store).Get",
+				"1 unexercised interface implementations",
+			},
+		},
 		{
 			name: "low coverage functions",
 			result: &AnalysisResult{
@@ -116,6 +228,111 @@ func TestPrintResults(t *testing.T) {
 				"3 low coverage functions",
 			},
 		},
+		{
+			name: "low coverage with hit counts",
+			result: &AnalysisResult{
+				LowCoverageFuncs: []LowCoverageFunc{
+					{File: "foo.go", Line: 10, Name: "FuncA", Coverage: 50.0, Threshold: 80.0, MinHits: 0, MaxHits: 12},
+				},
+			},
+			baseDir: "/test/project",
+			wantContains: []string{
+				"Line 10: FuncA (50.0%, hits 0-12)",
+			},
+		},
+		{
+			name: "low coverage with uncovered lines",
+			result: &AnalysisResult{
+				LowCoverageFuncs: []LowCoverageFunc{
+					{
+						File: "user.go", Line: 25, Name: "CreateUser", Coverage: 45.5, Threshold: 80.0,
+						Uncovered: []LineRange{{31, 32}, {40, 40}},
+						UncoveredSource: []SourceLine{
+							{Line: 31, Text: "\tif err != nil {"},
+							{Line: 32, Text: "\t\treturn nil, err"},
+							{Line: 40, Text: "\treturn nil, errNotFound"},
+						},
+					},
+				},
+			},
+			baseDir: "/test/project",
+			wantContains: []string{
+				"Line 25: CreateUser (45.5%)\n    uncovered: 31-32, 40\n",
+				"      31 | \tif err != nil {\n      32 | \t\treturn nil, err\n         ...\n      40 | \treturn nil, errNotFound\n",
+			},
+		},
+		{
+			name: "barely exercised functions",
+			result: &AnalysisResult{
+				BarelyExercisedFuncs: []BarelyExercisedFunc{
+					{File: "b.go", Line: 3, Name: "Once", Coverage: 100, MinHits: 1, MaxHits: 1, Limit: 2},
+					{File: "a.go", Line: 9, Name: "Twice", Receiver: "T", Coverage: 60, MinHits: 0, MaxHits: 2, Limit: 2},
+				},
+			},
+			baseDir: "/test/project",
+			wantContains: []string{
+				"BARELY EXERCISED FUNCTIONS (at most 2 hits) (2)",
+				"  b.go:3: Once (max 1 hits, 100.0%)\n  a.go:9: (T).Twice (max 2 hits, 60.0%)",
+				"2 barely exercised functions",
+			},
+		},
+		{
+			name: "cross-package coverage",
+			result: &AnalysisResult{
+				CrossPackageFuncs: []CrossPackageFunc{
+					{File: "lib/retry.go", Line: 7, Name: "Do", Coverage: 83.3, Packages: []string{"example.com/app/api", "example.com/app/lib"}},
+				},
+			},
+			baseDir: "/test/project",
+			wantContains: []string{
+				"CROSS-PACKAGE COVERAGE (1)",
+				"lib/retry.go:",
+				"Line 7: Do (83.3%) <- example.com/app/api, example.com/app/lib",
+				"1 functions covered by other packages' tests",
+			},
+		},
+		{
+			name: "coverage by build tag set",
+			result: &AnalysisResult{
+				TagSets: []string{"default", "integration"},
+				TagSetCoverageDiffs: []TagSetCoverage{
+					{File: "db/store.go", Line: 12, Name: "Query", Receiver: "Store", Coverage: []float64{0, 85.7}},
+				},
+			},
+			baseDir: "/test/project",
+			wantContains: []string{
+				"COVERAGE BY BUILD TAG SET (default vs integration) (1)",
+				"db/store.go:",
+				"Line 12: (Store).Query (default: 0.0%, integration: 85.7%)",
+				"1 functions with coverage differing by tag set",
+			},
+		},
+		{
+			name: "per-test coverage matrix",
+			result: &AnalysisResult{
+				TestMatrix: []FuncTests{
+					{Func: FuncInfo{Name: "Parse", File: "parse.go", Line: 10}, Tests: []TestRef{{Name: "TestParse"}, {Name: "TestRun"}}},
+					{Func: FuncInfo{Name: "trim", File: "parse.go", Line: 30}, Tests: []TestRef{{Name: "TestRun"}}},
+				},
+				IncidentallyCoveredFuncs: []FuncTests{
+					{Func: FuncInfo{Name: "trim", File: "parse.go", Line: 30}, Tests: []TestRef{{Name: "TestRun"}}},
+				},
+				RedundantTests: []TestRef{
+					{Name: "TestParseAgain", File: "parse_test.go", Line: 42},
+				},
+			},
+			baseDir: "/test/project",
+			wantContains: []string{
+				"TEST COVERAGE MATRIX (2)",
+				"Line 10: Parse <- TestParse, TestRun",
+				"INCIDENTALLY COVERED FUNCTIONS (1)",
+				"Line 30: trim (only by TestRun)",
+				"TESTS WITHOUT UNIQUE COVERAGE (1)",
+				"parse_test.go:",
+				"Line 42: TestParseAgain",
+				"1 incidentally covered functions, 1 tests without unique coverage",
+			},
+		},
 	}
 
 	for _, tt := range tests {
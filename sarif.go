@@ -0,0 +1,179 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// sarifFingerprintKey is the partialFingerprints key of testvet findings. The
+// fingerprint leaves out line numbers so that findings stay matched when code
+// above them moves.
+const sarifFingerprintKey = "testvetFinding/v1"
+
+// sarifRules are the reporting descriptors of the run, in rule index order
+var sarifRules = []sarifRule{
+	{
+		ID:                   findingUntested,
+		Name:                 "UntestedFunction",
+		ShortDescription:     sarifMessage{Text: "Function not called from any test"},
+		FullDescription:      sarifMessage{Text: "The function or method is not called, referenced or otherwise exercised by any test of the module."},
+		DefaultConfiguration: sarifConfiguration{Level: "warning"},
+	},
+	{
+		ID:                   findingMisplaced,
+		Name:                 "MisplacedTest",
+		ShortDescription:     sarifMessage{Text: "Test in the wrong file"},
+		FullDescription:      sarifMessage{Text: "The test mostly calls functions of another source file than the one its test file is named after."},
+		DefaultConfiguration: sarifConfiguration{Level: "note"},
+	},
+	{
+		ID:                   findingLowCoverage,
+		Name:                 "LowCoverage",
+		ShortDescription:     sarifMessage{Text: "Function coverage below the threshold"},
+		FullDescription:      sarifMessage{Text: "The statement coverage of the function by go test is below the -threshold percentage."},
+		DefaultConfiguration: sarifConfiguration{Level: "warning"},
+	},
+}
+
+// sarifLog is the root of a SARIF 2.1.0 document
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+// sarifRun is a single run of testvet
+type sarifRun struct {
+	Tool               sarifTool                        `json:"tool"`
+	OriginalURIBaseIDs map[string]sarifArtifactLocation `json:"originalUriBaseIds"`
+	Results            []sarifResult                    `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+// sarifRule describes a kind of finding
+type sarifRule struct {
+	ID                   string             `json:"id"`
+	Name                 string             `json:"name"`
+	ShortDescription     sarifMessage       `json:"shortDescription"`
+	FullDescription      sarifMessage       `json:"fullDescription"`
+	DefaultConfiguration sarifConfiguration `json:"defaultConfiguration"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+// sarifResult is a single finding
+type sarifResult struct {
+	RuleID              string            `json:"ruleId"`
+	RuleIndex           int               `json:"ruleIndex"`
+	Level               string            `json:"level"`
+	Message             sarifMessage      `json:"message"`
+	Locations           []sarifLocation   `json:"locations"`
+	PartialFingerprints map[string]string `json:"partialFingerprints"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+// sarifArtifactLocation is a file, relative to the %SRCROOT% base when URIBaseID is set
+type sarifArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+// writeSARIFReport writes the functions without tests, misplaced tests and low
+// coverage functions as a SARIF 2.1.0 log
+func writeSARIFReport(w io.Writer, result *AnalysisResult, baseDir string) error {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "testvet",
+			Version:        toolVersion(),
+			InformationURI: "https://github.com/LeanerCloud/testvet",
+			Rules:          sarifRules,
+		}},
+		OriginalURIBaseIDs: map[string]sarifArtifactLocation{
+			"%SRCROOT%": {URI: sarifDirURI(baseDir)},
+		},
+		Results: []sarifResult{},
+	}
+
+	for _, f := range collectFindings(result) {
+		run.Results = append(run.Results, newSARIFResult(f))
+	}
+
+	log := sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(log)
+}
+
+// newSARIFResult returns the result of a finding, fingerprinted by its kind
+// and identity
+func newSARIFResult(f finding) sarifResult {
+	index := 0
+	for i, rule := range sarifRules {
+		if rule.ID == f.Kind {
+			index = i
+		}
+	}
+
+	hash := sha256.Sum256([]byte(f.Kind + "\x00" + filepath.ToSlash(strings.Join(f.Identity, "\x00"))))
+	return sarifResult{
+		RuleID:    f.Kind,
+		RuleIndex: index,
+		Level:     sarifRules[index].DefaultConfiguration.Level,
+		Message:   sarifMessage{Text: f.Message},
+		Locations: []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{
+			ArtifactLocation: sarifArtifactLocation{URI: (&url.URL{Path: filepath.ToSlash(f.File)}).String(), URIBaseID: "%SRCROOT%"},
+			Region:           sarifRegion{StartLine: f.Line},
+		}}},
+		PartialFingerprints: map[string]string{sarifFingerprintKey: hex.EncodeToString(hash[:16])},
+	}
+}
+
+// sarifDirURI returns the file URI of a directory, ending in a slash as SARIF
+// requires for base URIs
+func sarifDirURI(dir string) string {
+	uri := filepath.ToSlash(dir)
+	if !strings.HasPrefix(uri, "/") {
+		uri = "/" + uri // Windows drive letters
+	}
+	if !strings.HasSuffix(uri, "/") {
+		uri += "/"
+	}
+	return (&url.URL{Scheme: "file", Path: uri}).String()
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestWriteSARIFReport(t *testing.T) {
+	result := &AnalysisResult{
+		FunctionsWithoutTests: []FuncInfo{
+			{Name: "Start", Receiver: "Server", File: "server.go", Line: 12, Package: "example.com/p"},
+		},
+		MisplacedTests: []MisplacedTest{
+			{Test: TestInfo{Name: "TestParse", Line: 7}, ActualFile: "util_test.go", ExpectedFile: "parse_test.go"},
+		},
+		LowCoverageFuncs: []LowCoverageFunc{
+			{File: "my dir/run.go", Line: 10, Name: "Run", Coverage: 25, Threshold: 80, Uncovered: []LineRange{{12, 14}, {20, 20}}},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := writeSARIFReport(&buf, result, "/src/p"); err != nil {
+		t.Fatalf("writeSARIFReport failed: %v", err)
+	}
+	var log sarifLog
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatalf("invalid SARIF output: %v\n%s", err, buf.String())
+	}
+
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("version = %q with %d runs, want 2.1.0 with 1 run", log.Version, len(log.Runs))
+	}
+	run := log.Runs[0]
+	if got := run.OriginalURIBaseIDs["%SRCROOT%"].URI; got != "file:///src/p/" {
+		t.Errorf("%%SRCROOT%% = %q, want file:///src/p/", got)
+	}
+
+	tests := []struct {
+		ruleID  string
+		level   string
+		message string
+		uri     string
+		line    int
+	}{
+		{findingUntested, "warning", "(Server).Start is not called from any test", "server.go", 12},
+		{findingMisplaced, "note", "TestParse belongs in parse_test.go", "util_test.go", 7},
+		{findingLowCoverage, "warning", "Run has 25.0% statement coverage, below 80.0% (uncovered lines 12-14, 20)", "my%20dir/run.go", 10},
+	}
+	if len(run.Results) != len(tests) {
+		t.Fatalf("got %d results, want %d", len(run.Results), len(tests))
+	}
+	for i, tt := range tests {
+		r := run.Results[i]
+		if r.RuleID != tt.ruleID || run.Tool.Driver.Rules[r.RuleIndex].ID != tt.ruleID {
+			t.Errorf("result %d: rule %q (index %d), want %q", i, r.RuleID, r.RuleIndex, tt.ruleID)
+		}
+		if r.Level != tt.level {
+			t.Errorf("result %d: level = %q, want %q", i, r.Level, tt.level)
+		}
+		if r.Message.Text != tt.message {
+			t.Errorf("result %d: message = %q, want %q", i, r.Message.Text, tt.message)
+		}
+		loc := r.Locations[0].PhysicalLocation
+		if loc.ArtifactLocation.URI != tt.uri || loc.ArtifactLocation.URIBaseID != "%SRCROOT%" || loc.Region.StartLine != tt.line {
+			t.Errorf("result %d: location = %+v, want %s:%d", i, loc, tt.uri, tt.line)
+		}
+		if r.PartialFingerprints[sarifFingerprintKey] == "" {
+			t.Errorf("result %d: missing partial fingerprint", i)
+		}
+	}
+}
+
+func TestNewSARIFResult_Fingerprint(t *testing.T) {
+	fingerprint := func(r sarifResult) string { return r.PartialFingerprints[sarifFingerprintKey] }
+
+	base := newSARIFResult(finding{Kind: findingUntested, File: "parse.go", Line: 10, Identity: []string{"parse.go", "example.com/p", "Parse"}})
+	moved := newSARIFResult(finding{Kind: findingUntested, File: "parse.go", Line: 25, Identity: []string{"parse.go", "example.com/p", "Parse"}})
+	if fingerprint(base) != fingerprint(moved) {
+		t.Error("Fingerprint changed when the function moved to another line")
+	}
+
+	others := []sarifResult{
+		newSARIFResult(finding{Kind: findingUntested, File: "parse.go", Line: 10, Identity: []string{"parse.go", "example.com/p", "parse"}}),
+		newSARIFResult(finding{Kind: findingUntested, File: "other.go", Line: 10, Identity: []string{"other.go", "example.com/p", "Parse"}}),
+		newSARIFResult(finding{Kind: findingLowCoverage, File: "parse.go", Line: 10, Identity: []string{"parse.go", "example.com/p", "Parse"}}),
+	}
+	for _, other := range others {
+		if fingerprint(other) == fingerprint(base) {
+			t.Errorf("Fingerprint of %s %v equals that of a different finding", other.RuleID, other.Locations)
+		}
+	}
+}
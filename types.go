@@ -1,11 +1,32 @@
 package main
 
+import (
+	"fmt"
+	"strconv"
+)
+
 // FuncInfo holds information about a function
 type FuncInfo struct {
 	Name     string
 	File     string
 	Line     int
 	Receiver string // empty for regular functions, type name for methods
+	Package  string // import path, set only when type information is available
+
+	PackageName string // package clause name
+
+	Verdict   Verdict   // how the function was determined to be tested
+	Reason    string    // explanation of the verdict, e.g. the matched call or the call chain
+	TestStyle TestStyle // whether the function is called from black-box tests, white-box tests, or both
+}
+
+// Key returns the fully qualified key of the function (import path, receiver
+// type and name), or "" if the package is unknown
+func (f FuncInfo) Key() string {
+	if f.Package == "" {
+		return ""
+	}
+	return qualifiedFuncKey(f.Package, f.Receiver, f.Name)
 }
 
 // TestInfo holds information about a test function
@@ -14,22 +35,186 @@ type TestInfo struct {
 	File        string
 	Line        int
 	CalledFuncs []string // functions called within this test (from AST analysis)
+	CalledKeys  []string // fully qualified keys of called functions (type-checked analysis only)
+
+	PackageName      string   // package clause name (e.g. "foo" or "foo_test")
+	ImportedPackages []string // names under which the test file imports packages
+	External         bool     // declared in an external test package (black-box test)
+	Untyped          bool     // left out of the type-checked load (e.g. by build tags) and parsed by syntax only
+
+	ReferencedFuncs []string // functions used as values without being called (from AST analysis)
+	ReferencedKeys  []string // fully qualified keys of referenced functions (type-checked analysis only)
+
+	InterfaceCalls []string // qualified keys of interface methods called (type-checked analysis only)
+	ConcreteTypes  []string // qualified names of module types used (type-checked analysis only)
+
+	MethodCalls []MethodCall // methods called on local variables of known type (syntax-only analysis)
+}
+
+// MethodCall is a method called on a local variable whose type is known from
+// its declaration, e.g. f.Run() after f := &fixture{} or f := newFixture(t)
+type MethodCall struct {
+	Method      string
+	Type        string // receiver type name, empty if the variable was initialized by Constructor
+	Constructor string // function whose first result initialized the variable
 }
 
+// Verdict classifies how a function was determined to be tested, from most to
+// least confident
+type Verdict string
+
+const (
+	VerdictDirectCall        Verdict = "direct-call"        // called from a test
+	VerdictReceiverMatch     Verdict = "receiver-match"     // matched a Type_Method call name (syntax only)
+	VerdictSuffixHeuristic   Verdict = "suffix-heuristic"   // matched a call name ending in _Name (syntax only)
+	VerdictInterfaceDispatch Verdict = "interface-dispatch" // called through an interface with a concrete receiver
+	VerdictReferenced        Verdict = "referenced"         // used as a value from a test, never called
+	VerdictCallGraph         Verdict = "call-graph"         // reachable from a test through the call graph
+	VerdictCoverageOnly      Verdict = "coverage-only"      // not matched to any test, but covered by go test
+	VerdictIntegrationOnly   Verdict = "integration-only"   // not matched to any test, covered only by integration runs (-covdir)
+	VerdictCoverageUnknown   Verdict = "coverage-unknown"   // not matched to any test, and the package's tests failed
+	VerdictUntested          Verdict = "untested"
+)
+
+// TestStyle tells whether a function is called from tests in an external test
+// package (package foo_test), from tests inside the package, or both
+type TestStyle string
+
+const (
+	TestStyleNone     TestStyle = ""
+	TestStyleBlackBox TestStyle = "black-box" // only from external test packages
+	TestStyleWhiteBox TestStyle = "white-box" // only from tests inside the package
+	TestStyleBoth     TestStyle = "both"
+)
+
 // AnalysisResult holds the analysis results
 type AnalysisResult struct {
-	FunctionsWithoutTests []FuncInfo
-	MisplacedTests        []MisplacedTest
-	LowCoverageFuncs      []LowCoverageFunc
+	Functions                []FuncInfo // all analyzed functions with their verdicts
+	Tests                    []TestInfo // all analyzed tests, sorted by file and line
+	FunctionsWithoutTests    []FuncInfo
+	HeuristicallyTestedFuncs []FuncInfo // functions considered tested only through heuristics or coverage
+	ReferencedOnlyFuncs      []FuncInfo // functions referenced from tests as values but never called
+	WhiteBoxOnlyFuncs        []FuncInfo // exported functions not called from black-box tests (only with -require-blackbox)
+	IntegrationOnlyFuncs     []FuncInfo // functions covered only by integration runs (only with -covdir)
+	IndirectlyTestedFuncs    []ReachedFunc
+	UnexercisedImpls         []UnexercisedImpl
+	MisplacedTests           []MisplacedTest
+	LowCoverageFuncs         []LowCoverageFunc
+	BarelyExercisedFuncs     []BarelyExercisedFunc // ranked by highest hit count (only with -max-hits)
+	FailedPackages           []FailedPackage       // packages whose tests failed during the coverage run
+	CoverageUnknownFuncs     []FuncInfo            // functions without other evidence in failed packages
+	CrossPackageFuncs        []CrossPackageFunc    // functions executed by other packages' tests (only with -coverpkg)
+
+	// Coverage per build tag set (only with -compare-tags)
+	TagSets             []string
+	TagSetCoverageDiffs []TagSetCoverage // functions whose coverage differs between tag sets
+
+	// Per-test coverage attribution (only with -test-matrix)
+	TestMatrix               []FuncTests // tests whose isolated run covered each function
+	IncidentallyCoveredFuncs []FuncTests // functions covered only by tests targeting something else
+	RedundantTests           []TestRef   // tests covering no block that another test does not cover
+}
+
+// ReachedFunc represents a function that is only reached from tests through other functions
+type ReachedFunc struct {
+	Func  FuncInfo
+	Chain []string // shortest call chain from a test, e.g. [TestX, A, B, Foo]
+}
+
+// TestRef identifies a test function, or all tests of a package when the
+// coverage matrix is built per package
+type TestRef struct {
+	Name    string // test function name, or package directory for per-package runs
+	File    string // test file, empty for per-package runs
+	Line    int
+	Package string // package directory relative to the analyzed directory
+}
+
+// FuncTests lists the tests whose isolated coverage run executed a function
+type FuncTests struct {
+	Func  FuncInfo
+	Tests []TestRef
+}
+
+// UnexercisedImpl represents an implementation of an interface method that tests
+// call through the interface, but never with this implementation as receiver
+type UnexercisedImpl struct {
+	Func      FuncInfo
+	Interface string // interface method, e.g. "(Store).Get"
 }
 
 // LowCoverageFunc represents a function with coverage below the threshold
 type LowCoverageFunc struct {
-	File       string
-	Line       int
-	Name       string
-	Coverage   float64
-	Threshold  float64
+	File      string
+	Line      int
+	Name      string
+	Receiver  string
+	Coverage  float64
+	Threshold float64
+	MinHits   int // lowest execution count of the function's blocks (count and atomic mode only)
+	MaxHits   int // highest execution count of the function's blocks (count and atomic mode only)
+
+	Uncovered       []LineRange  // lines of statements that were never executed
+	UncoveredSource []SourceLine // source of the uncovered lines (only with -show-uncovered)
+}
+
+// SourceLine is a numbered line of a source file
+type SourceLine struct {
+	Line int
+	Text string
+}
+
+// BarelyExercisedFunc is a covered function none of whose blocks ran more than Limit times
+type BarelyExercisedFunc struct {
+	File     string
+	Line     int
+	Name     string
+	Receiver string
+	Coverage float64
+	MinHits  int
+	MaxHits  int
+	Limit    int
+}
+
+// FailedPackage is a package whose tests failed or did not build during the coverage run
+type FailedPackage struct {
+	Package     string   // import path
+	Dir         string   // directory relative to the analyzed directory, empty if outside of it
+	Tests       []string // failed tests, in the order they failed
+	BuildFailed bool
+}
+
+// CrossPackageFunc is a function executed by the tests of other packages (only with -coverpkg)
+type CrossPackageFunc struct {
+	File     string
+	Line     int
+	Name     string
+	Receiver string
+	Coverage float64
+	Packages []string // import paths of the packages whose tests executed the function
+}
+
+// TagSetCoverage is the coverage of a function under every build tag set that was run
+type TagSetCoverage struct {
+	File     string
+	Line     int
+	Name     string
+	Receiver string
+	Coverage []float64 // in the order of AnalysisResult.TagSets
+}
+
+// LineRange is an inclusive range of source lines
+type LineRange struct {
+	Start int
+	End   int
+}
+
+// String formats the range as "12-14", or "12" for a single line
+func (r LineRange) String() string {
+	if r.Start == r.End {
+		return strconv.Itoa(r.Start)
+	}
+	return fmt.Sprintf("%d-%d", r.Start, r.End)
 }
 
 // MisplacedTest represents a test in the wrong file
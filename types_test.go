@@ -0,0 +1,24 @@
+package main
+
+import "testing"
+
+func TestFuncInfoKey(t *testing.T) {
+	f := FuncInfo{Name: "Get", Receiver: "Store"}
+	if got := f.Key(); got != "" {
+		t.Errorf("Key() without package = %q, want empty", got)
+	}
+
+	f.Package = "example.com/pkg"
+	if got := f.Key(); got != "(example.com/pkg.Store).Get" {
+		t.Errorf("Key() = %q, want %q", got, "(example.com/pkg.Store).Get")
+	}
+}
+
+func TestLineRangeString(t *testing.T) {
+	if got := (LineRange{Start: 12, End: 14}).String(); got != "12-14" {
+		t.Errorf("Expected 12-14, got %q", got)
+	}
+	if got := (LineRange{Start: 7, End: 7}).String(); got != "7" {
+		t.Errorf("Expected 7, got %q", got)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// coverageTestedThreshold is the coverage at which a function not matched to
+// any test is still considered tested
+const coverageTestedThreshold = 50.0
+
+// testEvidence holds everything known about how functions are exercised by tests
+type testEvidence struct {
+	calledFuncs     map[string]bool          // names and keys called from tests
+	exercisedImpls  map[string]bool          // keys of interface implementations exercised with a concrete receiver
+	referencedFuncs map[string]bool          // names and keys referenced as values from tests
+	chains          map[string][]string      // call chains from tests, keyed by function key
+	coverageMap     map[funcPosition]float64 // coverage percentage by function position
+	integrationOnly map[funcPosition]bool    // functions covered only by integration runs
+	unknownDirs     map[string]bool          // directories of packages whose tests failed, coverage unknown
+
+	blackBoxCalls map[string]bool // names and keys called from external test packages
+	whiteBoxCalls map[string]bool // names and keys called from tests inside the package
+}
+
+// classifyFunctions assigns a verdict and reason to every function, returning
+// them sorted by file and line
+func classifyFunctions(fileFunctions map[string][]FuncInfo, evidence testEvidence) []FuncInfo {
+	var result []FuncInfo
+	for _, funcs := range fileFunctions {
+		for _, f := range funcs {
+			f.Verdict, f.Reason = classifyFunction(f, evidence)
+			f.TestStyle = classifyTestStyle(f, evidence)
+			result = append(result, f)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].File != result[j].File {
+			return result[i].File < result[j].File
+		}
+		return result[i].Line < result[j].Line
+	})
+
+	return result
+}
+
+// classifyFunction determines the most confident verdict supported by the evidence
+func classifyFunction(f FuncInfo, evidence testEvidence) (Verdict, string) {
+	switch verdict, matched := matchCalledFunction(f, evidence.calledFuncs); verdict {
+	case VerdictDirectCall:
+		return verdict, "called from a test"
+	case VerdictReceiverMatch, VerdictSuffixHeuristic:
+		return verdict, fmt.Sprintf("matched call %s", matched)
+	}
+
+	if evidence.exercisedImpls[f.Key()] {
+		return VerdictInterfaceDispatch, "called through an interface with a concrete receiver"
+	}
+	if evidence.referencedFuncs != nil && isFunctionTested(f, evidence.referencedFuncs) {
+		return VerdictReferenced, "referenced as a value from a test"
+	}
+	if chain, ok := evidence.chains[f.Key()]; ok {
+		return VerdictCallGraph, "via " + strings.Join(chain, " -> ")
+	}
+	pos := funcPosition{File: f.File, Line: f.Line}
+	if cov, ok := evidence.coverageMap[pos]; ok && cov >= coverageTestedThreshold {
+		if evidence.integrationOnly[pos] {
+			return VerdictIntegrationOnly, fmt.Sprintf("%.1f%% statement coverage from integration runs only", cov)
+		}
+		return VerdictCoverageOnly, fmt.Sprintf("%.1f%% statement coverage", cov)
+	}
+	if evidence.unknownDirs[filepath.Dir(f.File)] {
+		return VerdictCoverageUnknown, "tests of the package failed"
+	}
+
+	return VerdictUntested, ""
+}
+
+// classifyTestStyle reports whether a function is called from black-box tests,
+// white-box tests, or both
+func classifyTestStyle(f FuncInfo, evidence testEvidence) TestStyle {
+	blackBox := evidence.blackBoxCalls != nil && isFunctionTested(f, evidence.blackBoxCalls)
+	whiteBox := evidence.whiteBoxCalls != nil && isFunctionTested(f, evidence.whiteBoxCalls)
+	switch {
+	case blackBox && whiteBox:
+		return TestStyleBoth
+	case blackBox:
+		return TestStyleBlackBox
+	case whiteBox:
+		return TestStyleWhiteBox
+	}
+	return TestStyleNone
+}
+
+// isExportedFunc reports whether a function is part of its package's API:
+// an exported function, or an exported method of an exported type
+func isExportedFunc(f FuncInfo) bool {
+	return isExportedUnit(f.Name) && (f.Receiver == "" || ast.IsExported(f.Receiver))
+}
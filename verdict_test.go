@@ -0,0 +1,119 @@
+package main
+
+import "testing"
+
+func TestClassifyFunction(t *testing.T) {
+	evidence := testEvidence{
+		calledFuncs: map[string]bool{
+			"Direct":                  true,
+			"MyType_Exact":            true,
+			"strings_Split":           true,
+			"example.com/p.TypedFunc": true,
+		},
+		exercisedImpls:  map[string]bool{"(example.com/p.memStore).Get": true},
+		referencedFuncs: map[string]bool{"handleHealth": true},
+		chains:          map[string][]string{"example.com/p.Deep": {"TestA", "A", "Deep"}},
+		coverageMap: map[funcPosition]float64{
+			{File: "cov.go", Line: 3}: 75,
+			{File: "cov.go", Line: 9}: 20,
+			{File: "e2e.go", Line: 5}: 80,
+		},
+		integrationOnly: map[funcPosition]bool{{File: "e2e.go", Line: 5}: true},
+		unknownDirs:     map[string]bool{"broken": true},
+	}
+
+	tests := []struct {
+		name        string
+		funcInfo    FuncInfo
+		wantVerdict Verdict
+		wantReason  string
+	}{
+		{"direct call", FuncInfo{Name: "Direct"}, VerdictDirectCall, "called from a test"},
+		{"typed direct call", FuncInfo{Name: "TypedFunc", Package: "example.com/p"}, VerdictDirectCall, "called from a test"},
+		{"receiver match", FuncInfo{Name: "Exact", Receiver: "MyType"}, VerdictReceiverMatch, "matched call MyType_Exact"},
+		{"suffix heuristic", FuncInfo{Name: "Split"}, VerdictSuffixHeuristic, "matched call strings_Split"},
+		{"interface dispatch", FuncInfo{Name: "Get", Receiver: "memStore", Package: "example.com/p"}, VerdictInterfaceDispatch, "called through an interface with a concrete receiver"},
+		{"referenced", FuncInfo{Name: "handleHealth"}, VerdictReferenced, "referenced as a value from a test"},
+		{"call graph", FuncInfo{Name: "Deep", Package: "example.com/p"}, VerdictCallGraph, "via TestA -> A -> Deep"},
+		{"coverage only", FuncInfo{Name: "Covered", File: "cov.go", Line: 3}, VerdictCoverageOnly, "75.0% statement coverage"},
+		{"integration only", FuncInfo{Name: "Serve", File: "e2e.go", Line: 5}, VerdictIntegrationOnly, "80.0% statement coverage from integration runs only"},
+		{"low coverage", FuncInfo{Name: "Barely", File: "cov.go", Line: 9}, VerdictUntested, ""},
+		{"coverage of another function", FuncInfo{Name: "Covered", File: "other.go", Line: 3}, VerdictUntested, ""},
+		{"coverage unknown", FuncInfo{Name: "Open", File: "broken/db.go"}, VerdictCoverageUnknown, "tests of the package failed"},
+		{"called in failed package", FuncInfo{Name: "Direct", File: "broken/db.go"}, VerdictDirectCall, "called from a test"},
+		{"untested", FuncInfo{Name: "Nothing"}, VerdictUntested, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verdict, reason := classifyFunction(tt.funcInfo, evidence)
+			if verdict != tt.wantVerdict || reason != tt.wantReason {
+				t.Errorf("classifyFunction(%v) = (%s, %q), want (%s, %q)", tt.funcInfo, verdict, reason, tt.wantVerdict, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestClassifyFunctions_Sorting(t *testing.T) {
+	fileFunctions := map[string][]FuncInfo{
+		"b.go": {{Name: "B", File: "b.go", Line: 1}},
+		"a.go": {{Name: "A2", File: "a.go", Line: 20}, {Name: "A1", File: "a.go", Line: 10}},
+	}
+
+	result := classifyFunctions(fileFunctions, testEvidence{calledFuncs: map[string]bool{"A1": true}})
+	if len(result) != 3 {
+		t.Fatalf("Expected 3 functions, got %d", len(result))
+	}
+
+	expectedOrder := []string{"A1", "A2", "B"}
+	for i, expected := range expectedOrder {
+		if result[i].Name != expected {
+			t.Errorf("Position %d: expected %s, got %s", i, expected, result[i].Name)
+		}
+	}
+	if result[0].Verdict != VerdictDirectCall || result[1].Verdict != VerdictUntested {
+		t.Errorf("Unexpected verdicts: %s, %s", result[0].Verdict, result[1].Verdict)
+	}
+}
+
+func TestClassifyTestStyle(t *testing.T) {
+	evidence := testEvidence{
+		blackBoxCalls: map[string]bool{"Both": true, "Black": true},
+		whiteBoxCalls: map[string]bool{"Both": true, "White": true},
+	}
+
+	tests := []struct {
+		name string
+		want TestStyle
+	}{
+		{"Both", TestStyleBoth},
+		{"Black", TestStyleBlackBox},
+		{"White", TestStyleWhiteBox},
+		{"Neither", TestStyleNone},
+	}
+
+	for _, tt := range tests {
+		if got := classifyTestStyle(FuncInfo{Name: tt.name}, evidence); got != tt.want {
+			t.Errorf("classifyTestStyle(%s) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestIsExportedFunc(t *testing.T) {
+	tests := []struct {
+		funcInfo FuncInfo
+		want     bool
+	}{
+		{FuncInfo{Name: "Parse"}, true},
+		{FuncInfo{Name: "parse"}, false},
+		{FuncInfo{Name: "Do", Receiver: "Client"}, true},
+		{FuncInfo{Name: "Do", Receiver: "client"}, false},
+		{FuncInfo{Name: "DefaultServer.Handler"}, true},
+	}
+
+	for _, tt := range tests {
+		if got := isExportedFunc(tt.funcInfo); got != tt.want {
+			t.Errorf("isExportedFunc(%v) = %v, want %v", tt.funcInfo, got, tt.want)
+		}
+	}
+}